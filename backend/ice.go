@@ -0,0 +1,181 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+// watchICEConfigReload re-reads the ICE config file on SIGHUP so operators
+// can rotate TURN credentials or add/remove servers without a restart.
+func watchICEConfigReload() {
+	sigCh := make(chan os.Signal, 1)
+	signal.Notify(sigCh, syscall.SIGHUP)
+	for range sigCh {
+		if err := iceMgr.reload(); err != nil {
+			log.Printf("⚠️  Failed to reload ICE config: %v", err)
+			continue
+		}
+		log.Printf("🔄 Reloaded ICE config from %s", iceMgr.path)
+	}
+}
+
+// defaultTURNCredentialTTL is how long a coturn REST credential minted by
+// /api/ice stays valid when the config file doesn't say otherwise.
+const defaultTURNCredentialTTL = 1 * time.Hour
+
+// iceServerConfig is one entry of the -ice-config JSON file.
+type iceServerConfig struct {
+	URLs           []string `json:"urls"`
+	Username       string   `json:"username,omitempty"`
+	Credential     string   `json:"credential,omitempty"`
+	CredentialType string   `json:"credentialType,omitempty"`
+}
+
+// iceFileConfig is the shape of the -ice-config JSON file. TURNSecret, when
+// set, is used to mint short-lived coturn REST credentials (per the coturn
+// REST API convention) for any server entry that doesn't already carry its
+// own Username/Credential.
+type iceFileConfig struct {
+	ICEServers   []iceServerConfig `json:"iceServers"`
+	TURNSecret   string            `json:"turnSecret,omitempty"`
+	TURNUsername string            `json:"turnUsername,omitempty"`
+	TTLSeconds   int               `json:"ttlSeconds,omitempty"`
+}
+
+// iceManager owns the currently configured ICE server list and reloads it
+// from disk, either at startup or on SIGHUP.
+type iceManager struct {
+	path string
+
+	mu     sync.RWMutex
+	config iceFileConfig
+}
+
+// newICEManager creates a manager pointed at path, seeded with the same
+// pair of public Google STUN servers the vault used before this became
+// configurable, so a missing config file still leaves WebRTC usable.
+func newICEManager(path string) *iceManager {
+	return &iceManager{
+		path: path,
+		config: iceFileConfig{
+			ICEServers: []iceServerConfig{
+				{URLs: []string{"stun:stun.l.google.com:19302", "stun:stun1.l.google.com:19302"}},
+			},
+		},
+	}
+}
+
+// reload re-reads the config file from disk, replacing the in-memory config
+// on success. It leaves the previous config in place on error so a bad or
+// briefly-missing file doesn't take the server's ICE servers offline.
+func (m *iceManager) reload() error {
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return fmt.Errorf("reading ice config %s: %w", m.path, err)
+	}
+
+	var cfg iceFileConfig
+	if err := json.Unmarshal(data, &cfg); err != nil {
+		return fmt.Errorf("parsing ice config %s: %w", m.path, err)
+	}
+
+	m.mu.Lock()
+	m.config = cfg
+	m.mu.Unlock()
+	return nil
+}
+
+// webRTCServers builds the []webrtc.ICEServer list for a Pion PeerConnection,
+// minting fresh TURN REST credentials where the config calls for them.
+func (m *iceManager) webRTCServers() []webrtc.ICEServer {
+	m.mu.RLock()
+	cfg := m.config
+	m.mu.RUnlock()
+
+	servers := make([]webrtc.ICEServer, 0, len(cfg.ICEServers))
+	for _, entry := range cfg.ICEServers {
+		username, credential := entry.Username, entry.Credential
+		if cfg.TURNSecret != "" && username == "" && credential == "" {
+			username, credential = turnRESTCredentials(cfg.TURNSecret, cfg.TURNUsername, cfg.ttl())
+		}
+		servers = append(servers, webrtc.ICEServer{
+			URLs:           entry.URLs,
+			Username:       username,
+			Credential:     credential,
+			CredentialType: parseCredentialType(entry.CredentialType),
+		})
+	}
+	return servers
+}
+
+// ttl returns the configured TURN credential lifetime, or the default.
+func (c *iceFileConfig) ttl() time.Duration {
+	if c.TTLSeconds <= 0 {
+		return defaultTURNCredentialTTL
+	}
+	return time.Duration(c.TTLSeconds) * time.Second
+}
+
+func parseCredentialType(t string) webrtc.ICECredentialType {
+	if t == "oauth" {
+		return webrtc.ICECredentialTypeOauth
+	}
+	return webrtc.ICECredentialTypePassword
+}
+
+// turnRESTCredentials computes short-lived TURN credentials following the
+// coturn REST API convention: username = "<expiry-unix>:<user>", credential
+// = base64(HMAC-SHA1(secret, username)).
+func turnRESTCredentials(secret, user string, ttl time.Duration) (username, credential string) {
+	expiry := time.Now().Add(ttl).Unix()
+	username = fmt.Sprintf("%d:%s", expiry, user)
+
+	mac := hmac.New(sha1.New, []byte(secret))
+	mac.Write([]byte(username))
+	credential = base64.StdEncoding.EncodeToString(mac.Sum(nil))
+	return username, credential
+}
+
+// apiICEServer is the wire shape returned by GET /api/ice.
+type apiICEServer struct {
+	URLs           []string `json:"urls"`
+	Username       string   `json:"username,omitempty"`
+	Credential     string   `json:"credential,omitempty"`
+	CredentialType string   `json:"credentialType,omitempty"`
+}
+
+// handleICEConfig exposes the effective ICE server list (with freshly
+// minted TURN REST credentials, if configured) so the frontend doesn't have
+// to hardcode STUN/TURN servers either.
+func handleICEConfig(w http.ResponseWriter, r *http.Request) {
+	servers := iceMgr.webRTCServers()
+	out := make([]apiICEServer, 0, len(servers))
+	for _, s := range servers {
+		credentialType := "password"
+		if s.CredentialType == webrtc.ICECredentialTypeOauth {
+			credentialType = "oauth"
+		}
+		credential, _ := s.Credential.(string)
+		out = append(out, apiICEServer{
+			URLs:           s.URLs,
+			Username:       s.Username,
+			Credential:     credential,
+			CredentialType: credentialType,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]interface{}{"iceServers": out})
+}
@@ -4,9 +4,12 @@ import (
 	"crypto/rand"
 	"encoding/hex"
 	"encoding/json"
+	"flag"
 	"log"
 	"net/http"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/gorilla/websocket"
@@ -15,22 +18,34 @@ import (
 
 // Message types for WebSocket signaling
 const (
-	MsgTypeJoin       = "join"
-	MsgTypeOffer      = "offer"
-	MsgTypeAnswer     = "answer"
-	MsgTypeICE        = "ice"
-	MsgTypeReady      = "ready"
-	MsgTypePeerJoined = "peer-joined"
-	MsgTypeError      = "error"
-	MsgTypeExpired    = "expired"
+	MsgTypeJoin           = "join"
+	MsgTypeOffer          = "offer"
+	MsgTypeAnswer         = "answer"
+	MsgTypeICE            = "ice"
+	MsgTypeReady          = "ready"
+	MsgTypePeerJoined     = "peer-joined"
+	MsgTypeReceiverJoined = "receiver-joined"
+	MsgTypeRequestOffer   = "request-offer"
+	MsgTypeRelayRequest   = "relay-request"
+	MsgTypeError          = "error"
+	MsgTypeExpired        = "expired"
 )
 
-// SignalMessage represents WebSocket messages
+// SignalMessage represents WebSocket messages. RPCID is only populated for
+// peers speaking the jsonrpc protocol and is never marshaled onto the wire
+// directly; peerWriter/readPeerMessage translate it into/out of the JSON-RPC
+// envelope so the legacy framing stays byte-for-byte unchanged.
 type SignalMessage struct {
-	Type      string          `json:"type"`
-	SessionID string          `json:"sessionId,omitempty"`
-	PeerID    string          `json:"peerId,omitempty"`
-	Payload   json.RawMessage `json:"payload,omitempty"`
+	Type string `json:"type"`
+	// TargetPeerID identifies which peer in the room a relay message is for.
+	// Required when a sender relays to a receiver (a room can hold several);
+	// set automatically by BroadcastToSession when a receiver relays to the
+	// session's sender, since that direction is always unambiguous.
+	TargetPeerID string          `json:"targetPeerId,omitempty"`
+	SessionID    string          `json:"sessionId,omitempty"`
+	PeerID       string          `json:"peerId,omitempty"`
+	Payload      json.RawMessage `json:"payload,omitempty"`
+	RPCID        json.RawMessage `json:"-"`
 }
 
 // Peer represents a connected client
@@ -38,26 +53,54 @@ type Peer struct {
 	ID             string
 	Conn           *websocket.Conn
 	Role           string // "sender" or "receiver"
+	Protocol       string // "legacy" or "jsonrpc"
 	SendChan       chan SignalMessage
 	PeerConnection *webrtc.PeerConnection
-	DataChannel    *webrtc.DataChannel
-	SessionID      string
-	mu             sync.Mutex
+	// SenderConnections holds one RTCPeerConnection per receiver this peer
+	// (when acting as a session's sender) is paired with, keyed by receiver
+	// peer ID. Only populated for sender peers; receivers only ever need
+	// the single PeerConnection above.
+	SenderConnections map[string]*webrtc.PeerConnection
+	DataChannel       *webrtc.DataChannel
+	SessionID         string
+	mu                sync.Mutex
 }
 
-// Session represents a vault session
+// MaxReceiversPerSession bounds the fan-out of a single broadcast vault
+// session so one sender can't be made to juggle unbounded PeerConnections.
+const MaxReceiversPerSession = 8
+
+// Room holds the peer topology of a vault session: one sender and up to
+// MaxReceiversPerSession receivers pulling the same file.
+type Room struct {
+	Sender    *Peer
+	Receivers map[string]*Peer
+	// Relays holds an active server-side relay fallback (see relay.go) per
+	// receiver whose P2P connection to the sender failed, keyed by receiver
+	// peer ID. Most sessions never populate this.
+	Relays map[string]*Relay
+}
+
+// Session represents the locally-hosted view of a vault session: the peers
+// of it that actually connected to this pod. Cluster-wide truth (who holds
+// which slot, across every pod) lives in the SessionStore instead.
 type Session struct {
 	ID        string
-	Sender    *Peer
-	Receiver  *Peer
+	Room      Room
 	CreatedAt time.Time
 	mu        sync.RWMutex
+	// stopWatch cancels the goroutine forwarding this session's SessionStore
+	// events/messages to its locally-hosted peers; set once, by the
+	// watchSession goroutine AddPeer starts.
+	stopWatch func()
 }
 
-// SessionManager manages all active vault sessions
+// SessionManager manages the peers connected to this pod and forwards
+// cross-pod session topology/messages to and from the SessionStore.
 type SessionManager struct {
 	sessions map[string]*Session
 	mu       sync.RWMutex
+	store    SessionStore
 }
 
 var (
@@ -65,41 +108,37 @@ var (
 		CheckOrigin: func(r *http.Request) bool {
 			return true // Allow all origins for demo (restrict in production)
 		},
+		Subprotocols: []string{jsonRPCSubprotocol},
 	}
 	sessionManager *SessionManager
 
-	// Pion WebRTC configuration
-	webrtcConfig = webrtc.Configuration{
-		ICEServers: []webrtc.ICEServer{
-			{
-				URLs: []string{
-					"stun:stun.l.google.com:19302",
-					"stun:stun1.l.google.com:19302",
-				},
-			},
-		},
-	}
+	// iceMgr holds the operator-configured ICE server list (see ice.go),
+	// loaded from -ice-config and reloadable on SIGHUP.
+	iceMgr *iceManager
 )
 
-// NewSessionManager creates a new session manager
-func NewSessionManager() *SessionManager {
-	sm := &SessionManager{
+// currentWebRTCConfig builds the Pion configuration to use for a new
+// PeerConnection from whatever ICE servers are currently loaded.
+func currentWebRTCConfig() webrtc.Configuration {
+	return webrtc.Configuration{ICEServers: iceMgr.webRTCServers()}
+}
+
+// NewSessionManager creates a session manager backed by store for
+// cluster-wide topology and cross-pod message delivery.
+func NewSessionManager(store SessionStore) *SessionManager {
+	return &SessionManager{
 		sessions: make(map[string]*Session),
+		store:    store,
 	}
-	// Start cleanup goroutine
-	go sm.cleanupExpiredSessions()
-	return sm
 }
 
-// CreateSession creates a new vault session
+// CreateSession creates a new vault session in the backing store.
 func (sm *SessionManager) CreateSession() string {
-	sessionID := generateSessionID()
-	sm.mu.Lock()
-	sm.sessions[sessionID] = &Session{
-		ID:        sessionID,
-		CreatedAt: time.Now(),
+	sessionID, err := sm.store.Create()
+	if err != nil {
+		log.Printf("Error creating session: %v", err)
+		return ""
 	}
-	sm.mu.Unlock()
 	log.Printf("✨ Created session: %s", sessionID)
 	return sessionID
 }
@@ -112,142 +151,245 @@ func (sm *SessionManager) GetSession(sessionID string) (*Session, bool) {
 	return session, exists
 }
 
-// AddPeer adds a peer to a session
+// AddPeer adds a peer to a session's room, claiming its slot in the backing
+// store first so single-sender/MaxReceiversPerSession is enforced
+// cluster-wide rather than just within this pod.
 func (sm *SessionManager) AddPeer(sessionID string, peer *Peer) error {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
+	if err := sm.store.Claim(sessionID, peer.Role, peer.ID); err != nil {
+		return err
+	}
 
+	sm.mu.Lock()
 	session, exists := sm.sessions[sessionID]
 	if !exists {
-		return &ErrorResponse{Message: "Session not found"}
+		session = &Session{ID: sessionID, Room: Room{Receivers: make(map[string]*Peer)}, CreatedAt: time.Now()}
+		sm.sessions[sessionID] = session
+	}
+	sm.mu.Unlock()
+	if !exists {
+		go sm.watchSession(session)
 	}
 
 	session.mu.Lock()
-	defer session.mu.Unlock()
-
 	if peer.Role == "sender" {
-		if session.Sender != nil {
-			return &ErrorResponse{Message: "Sender already connected"}
-		}
-		session.Sender = peer
+		session.Room.Sender = peer
 		log.Printf("📤 Sender joined session: %s", sessionID)
 	} else {
-		if session.Receiver != nil {
-			return &ErrorResponse{Message: "Receiver already connected"}
-		}
-		session.Receiver = peer
-		log.Printf("📥 Receiver joined session: %s", sessionID)
+		session.Room.Receivers[peer.ID] = peer
+		log.Printf("📥 Receiver %s joined session: %s (%d/%d)", peer.ID, sessionID, len(session.Room.Receivers), MaxReceiversPerSession)
 	}
+	session.mu.Unlock()
 
 	return nil
 }
 
-// RemovePeer removes a peer from a session
+// RemovePeer removes a peer from its session's locally-hosted room and
+// releases its claim in the backing store.
 func (sm *SessionManager) RemovePeer(sessionID string, peerID string) {
-	sm.mu.Lock()
-	defer sm.mu.Unlock()
-
+	sm.mu.RLock()
 	session, exists := sm.sessions[sessionID]
+	sm.mu.RUnlock()
 	if !exists {
 		return
 	}
 
 	session.mu.Lock()
-	defer session.mu.Unlock()
-
-	if session.Sender != nil && session.Sender.ID == peerID {
-		if session.Sender.PeerConnection != nil {
-			session.Sender.PeerConnection.Close()
+	if session.Room.Sender != nil && session.Room.Sender.ID == peerID {
+		// Relays stand in for the sender too, so tear every one of them down
+		// before dropping the sender's own connections to avoid a relay
+		// outliving the real peer it was bridging.
+		for receiverID := range session.Room.Relays {
+			sm.stopRelayLocked(session, receiverID)
+		}
+		for receiverID, pc := range session.Room.Sender.SenderConnections {
+			pc.Close()
+			delete(session.Room.Sender.SenderConnections, receiverID)
 		}
-		session.Sender = nil
+		session.Room.Sender = nil
 		log.Printf("📤 Sender left session: %s", sessionID)
 	}
-	if session.Receiver != nil && session.Receiver.ID == peerID {
-		if session.Receiver.PeerConnection != nil {
-			session.Receiver.PeerConnection.Close()
+	if receiver, ok := session.Room.Receivers[peerID]; ok {
+		sm.stopRelayLocked(session, peerID)
+		if receiver.PeerConnection != nil {
+			receiver.PeerConnection.Close()
 		}
-		session.Receiver = nil
-		log.Printf("📥 Receiver left session: %s", sessionID)
+		if session.Room.Sender != nil {
+			if pc, ok := session.Room.Sender.SenderConnections[peerID]; ok {
+				pc.Close()
+				delete(session.Room.Sender.SenderConnections, peerID)
+			}
+		}
+		delete(session.Room.Receivers, peerID)
+		log.Printf("📥 Receiver %s left session: %s", peerID, sessionID)
+	}
+	roomEmpty := session.Room.Sender == nil && len(session.Room.Receivers) == 0
+	session.mu.Unlock()
+
+	if err := sm.store.Unclaim(sessionID, peerID); err != nil {
+		log.Printf("Warning: failed to release claim for peer %s in session %s: %v", peerID, sessionID, err)
 	}
 
-	// Clean up session if both peers are gone
-	if session.Sender == nil && session.Receiver == nil {
+	// Clean up local tracking once this pod hosts nobody from the room
+	// anymore; the session itself may still be alive on other pods.
+	if roomEmpty {
+		sm.mu.Lock()
 		delete(sm.sessions, sessionID)
-		log.Printf("🧹 Session cleaned up: %s", sessionID)
+		sm.mu.Unlock()
+		if session.stopWatch != nil {
+			session.stopWatch()
+		}
+		log.Printf("🧹 Local session tracking cleaned up: %s", sessionID)
 	}
 }
 
-// BroadcastToSession sends a message to the other peer in a session
-func (sm *SessionManager) BroadcastToSession(sessionID, senderPeerID string, msg SignalMessage) error {
-	session, exists := sm.GetSession(sessionID)
+// RelayToPeer sends msg to a specific peer in the session, identified by
+// peer ID, regardless of which pod actually hosts that peer's connection —
+// the backing store forwards it to whichever pod's watchSession is hosting
+// that peer.
+func (sm *SessionManager) RelayToPeer(sessionID, targetPeerID string, msg SignalMessage) error {
+	return sm.store.Publish(sessionID, targetPeerID, msg)
+}
+
+// BroadcastToSession routes a relay message to the right counterpart peer.
+// A receiver always relays to the session's (single, unambiguous) sender; a
+// sender must set msg.TargetPeerID to say which receiver the message is
+// for, since a room can now hold more than one. The sender's identity comes
+// from the store's cluster-wide topology, not this pod's local room, since
+// the sender may be connected to a different pod entirely.
+func (sm *SessionManager) BroadcastToSession(sessionID, fromPeerID string, msg SignalMessage) error {
+	meta, exists, err := sm.store.Get(sessionID)
+	if err != nil {
+		return err
+	}
 	if !exists {
 		return &ErrorResponse{Message: "Session not found"}
 	}
 
-	session.mu.RLock()
-	defer session.mu.RUnlock()
+	if meta.SenderID == fromPeerID {
+		if msg.TargetPeerID == "" {
+			return &ErrorResponse{Message: "targetPeerId required to relay from sender"}
+		}
+		return sm.RelayToPeer(sessionID, msg.TargetPeerID, msg)
+	}
 
-	var targetPeer *Peer
-	if session.Sender != nil && session.Sender.ID != senderPeerID {
-		targetPeer = session.Sender
-	} else if session.Receiver != nil && session.Receiver.ID != senderPeerID {
-		targetPeer = session.Receiver
+	if meta.SenderID == "" {
+		return &ErrorResponse{Message: "Sender not connected"}
 	}
+	msg.TargetPeerID = fromPeerID // tell the sender which receiver this came from
+	return sm.RelayToPeer(sessionID, meta.SenderID, msg)
+}
 
-	if targetPeer == nil {
-		return &ErrorResponse{Message: "Target peer not found"}
+// watchSession runs for as long as this pod hosts at least one local peer
+// in session, forwarding the backing store's topology events and relayed
+// messages to whichever of its locally-hosted peers they're addressed to.
+// This is how peerWriter ends up delivering both locally-originated and
+// cross-pod messages through the same per-peer SendChan.
+func (sm *SessionManager) watchSession(session *Session) {
+	events, stopEvents := sm.store.WatchPeerJoined(session.ID)
+	deliveries, stopDeliveries := sm.store.Subscribe(session.ID)
+	session.stopWatch = func() {
+		stopEvents()
+		stopDeliveries()
 	}
 
-	select {
-	case targetPeer.SendChan <- msg:
-		return nil
-	default:
-		return &ErrorResponse{Message: "Failed to send message"}
+	for {
+		select {
+		case evt, ok := <-events:
+			if !ok {
+				return
+			}
+			sm.handleStoreEvent(session, evt)
+		case env, ok := <-deliveries:
+			if !ok {
+				return
+			}
+			sm.deliverLocally(session, env)
+		}
 	}
 }
 
-// GetPeerPair returns both peers in a session
-func (sm *SessionManager) GetPeerPair(sessionID string) (*Peer, *Peer, bool) {
-	session, exists := sm.GetSession(sessionID)
-	if !exists {
-		return nil, nil, false
+// handleStoreEvent reacts to a cluster-wide topology event for session.
+func (sm *SessionManager) handleStoreEvent(session *Session, evt Event) {
+	switch evt.Type {
+	case EventPeerJoined:
+		if evt.Role != "receiver" {
+			return
+		}
+		session.mu.RLock()
+		sender := session.Room.Sender
+		session.mu.RUnlock()
+		if sender != nil {
+			log.Printf("📡 Notifying sender that receiver %s joined session: %s", evt.PeerID, session.ID)
+			sender.SendChan <- SignalMessage{Type: MsgTypeReceiverJoined, SessionID: session.ID, PeerID: evt.PeerID}
+		}
+	case EventPeerLeft:
+		// A relay may be running on this pod even though the peer that just
+		// left was hosted on another one, so teardown needs to react to the
+		// cluster-wide event rather than only RemovePeer's local-room path.
+		session.mu.Lock()
+		if evt.Role == "sender" {
+			for receiverID := range session.Room.Relays {
+				sm.stopRelayLocked(session, receiverID)
+			}
+		} else if evt.Role == "receiver" {
+			sm.stopRelayLocked(session, evt.PeerID)
+		}
+		session.mu.Unlock()
+	case EventExpired:
+		sm.expireLocalSession(session)
 	}
-	session.mu.RLock()
-	defer session.mu.RUnlock()
-	return session.Sender, session.Receiver, true
 }
 
-// cleanupExpiredSessions removes sessions older than 30 minutes
-func (sm *SessionManager) cleanupExpiredSessions() {
-	ticker := time.NewTicker(5 * time.Minute)
-	defer ticker.Stop()
+// deliverLocally hands a cross-pod message to whichever locally-hosted peer
+// it's addressed to; envelopes for peers hosted elsewhere are ignored here,
+// since that pod's own watchSession goroutine delivers them instead.
+func (sm *SessionManager) deliverLocally(session *Session, env DeliveryEnvelope) {
+	session.mu.RLock()
+	var target *Peer
+	if session.Room.Sender != nil && session.Room.Sender.ID == env.DeliverTo {
+		target = session.Room.Sender
+	} else if receiver, ok := session.Room.Receivers[env.DeliverTo]; ok {
+		target = receiver
+	}
+	session.mu.RUnlock()
 
-	for range ticker.C {
-		sm.mu.Lock()
-		now := time.Now()
-		for sessionID, session := range sm.sessions {
-			if now.Sub(session.CreatedAt) > 30*time.Minute {
-				session.mu.Lock()
-				// Notify peers of expiration and close peer connections
-				if session.Sender != nil {
-					if session.Sender.PeerConnection != nil {
-						session.Sender.PeerConnection.Close()
-					}
-					session.Sender.SendChan <- SignalMessage{Type: MsgTypeExpired}
-				}
-				if session.Receiver != nil {
-					if session.Receiver.PeerConnection != nil {
-						session.Receiver.PeerConnection.Close()
-					}
-					session.Receiver.SendChan <- SignalMessage{Type: MsgTypeExpired}
-				}
-				session.mu.Unlock()
-				delete(sm.sessions, sessionID)
-				log.Printf("⏰ Expired session: %s", sessionID)
-			}
+	if target == nil {
+		return
+	}
+	select {
+	case target.SendChan <- env.Message:
+	default:
+		log.Printf("Dropping message to slow peer %s", target.ID)
+	}
+}
+
+// expireLocalSession notifies and disconnects any locally-hosted peers for
+// session once the store reports it expired (Redis TTL, or
+// MemorySessionStore's own sweep), then stops watching it.
+func (sm *SessionManager) expireLocalSession(session *Session) {
+	session.mu.Lock()
+	if session.Room.Sender != nil {
+		for _, pc := range session.Room.Sender.SenderConnections {
+			pc.Close()
 		}
-		sm.mu.Unlock()
+		session.Room.Sender.SendChan <- SignalMessage{Type: MsgTypeExpired}
+	}
+	for _, receiver := range session.Room.Receivers {
+		if receiver.PeerConnection != nil {
+			receiver.PeerConnection.Close()
+		}
+		receiver.SendChan <- SignalMessage{Type: MsgTypeExpired}
 	}
+	session.mu.Unlock()
+
+	sm.mu.Lock()
+	delete(sm.sessions, session.ID)
+	sm.mu.Unlock()
+
+	if session.stopWatch != nil {
+		session.stopWatch()
+	}
+	log.Printf("⏰ Expired session: %s", session.ID)
 }
 
 // ErrorResponse represents an error message
@@ -273,10 +415,14 @@ func generatePeerID() string {
 	return hex.EncodeToString(b)
 }
 
-// createPeerConnection creates a new Pion WebRTC peer connection
-func createPeerConnection(peer *Peer) (*webrtc.PeerConnection, error) {
+// createPeerConnection creates a new Pion WebRTC peer connection for peer.
+// targetPeerID names the specific counterpart this connection negotiates
+// with (the other end of the pairing); it is empty for a receiver's single
+// connection to the sender, and is the receiver's ID for one of a sender's
+// per-receiver connections.
+func createPeerConnection(peer *Peer, targetPeerID string) (*webrtc.PeerConnection, error) {
 	// Create a new RTCPeerConnection using Pion
-	peerConnection, err := webrtc.NewPeerConnection(webrtcConfig)
+	peerConnection, err := webrtc.NewPeerConnection(currentWebRTCConfig())
 	if err != nil {
 		return nil, err
 	}
@@ -288,6 +434,7 @@ func createPeerConnection(peer *Peer) (*webrtc.PeerConnection, error) {
 		if connectionState == webrtc.ICEConnectionStateFailed ||
 			connectionState == webrtc.ICEConnectionStateDisconnected {
 			log.Printf("❌ ICE connection failed/disconnected for peer: %s", peer.ID)
+			maybeAutoRelayOnICEFailure(peer, targetPeerID)
 		}
 	})
 
@@ -317,9 +464,10 @@ func createPeerConnection(peer *Peer) (*webrtc.PeerConnection, error) {
 
 		// Send ICE candidate to the other peer
 		msg := SignalMessage{
-			Type:      MsgTypeICE,
-			SessionID: peer.SessionID,
-			Payload:   json.RawMessage(payload),
+			Type:         MsgTypeICE,
+			SessionID:    peer.SessionID,
+			TargetPeerID: targetPeerID,
+			Payload:      json.RawMessage(payload),
 		}
 
 		err = sessionManager.BroadcastToSession(peer.SessionID, peer.ID, msg)
@@ -331,7 +479,33 @@ func createPeerConnection(peer *Peer) (*webrtc.PeerConnection, error) {
 	return peerConnection, nil
 }
 
-// handleWebSocket handles WebSocket connections
+// senderPeerConnection returns the sender-side RTCPeerConnection dedicated
+// to peer's pairing with the receiver identified by receiverID, creating it
+// on first use. Each receiver gets its own PeerConnection on the sender so
+// fan-out negotiations don't collide with one another.
+func senderPeerConnection(peer *Peer, receiverID string) (*webrtc.PeerConnection, error) {
+	peer.mu.Lock()
+	defer peer.mu.Unlock()
+
+	if peer.SenderConnections == nil {
+		peer.SenderConnections = make(map[string]*webrtc.PeerConnection)
+	}
+	if pc, ok := peer.SenderConnections[receiverID]; ok {
+		return pc, nil
+	}
+
+	pc, err := createPeerConnection(peer, receiverID)
+	if err != nil {
+		return nil, err
+	}
+	peer.SenderConnections[receiverID] = pc
+	return pc, nil
+}
+
+// handleWebSocket handles WebSocket connections. It serves both the legacy
+// SignalMessage framing and a JSON-RPC 2.0 framing on the same /ws route,
+// content-negotiated via the "jsonrpc2.0" subprotocol, plus a /ws/jsonrpc
+// sibling route for clients that can't set a subprotocol header.
 func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 	conn, err := upgrader.Upgrade(w, r, nil)
 	if err != nil {
@@ -339,10 +513,16 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
+	protocol := "legacy"
+	if conn.Subprotocol() == jsonRPCSubprotocol || strings.HasSuffix(r.URL.Path, "/jsonrpc") {
+		protocol = "jsonrpc"
+	}
+
 	peerID := generatePeerID()
 	peer := &Peer{
 		ID:       peerID,
 		Conn:     conn,
+		Protocol: protocol,
 		SendChan: make(chan SignalMessage, 10),
 	}
 
@@ -363,22 +543,55 @@ func handleWebSocket(w http.ResponseWriter, r *http.Request) {
 
 	// Read messages from client
 	for {
-		var msg SignalMessage
-		err := conn.ReadJSON(&msg)
+		msg, err := readPeerMessage(peer)
 		if err != nil {
 			log.Printf("Read error from peer %s: %v", peerID, err)
 			break
 		}
+		if msg == nil {
+			// Malformed jsonrpc envelope; an error response was already sent.
+			continue
+		}
+
+		handleSignalMessage(peer, *msg)
+	}
+}
+
+// readPeerMessage reads one message off the wire and normalizes it to the
+// internal SignalMessage shape, regardless of which framing the peer uses.
+func readPeerMessage(peer *Peer) (*SignalMessage, error) {
+	var raw json.RawMessage
+	if err := peer.Conn.ReadJSON(&raw); err != nil {
+		return nil, err
+	}
+
+	if peer.Protocol != "jsonrpc" {
+		var msg SignalMessage
+		if err := json.Unmarshal(raw, &msg); err != nil {
+			return nil, err
+		}
+		return &msg, nil
+	}
 
-		handleSignalMessage(peer, msg)
+	msg, err := decodeRPCMessage(raw)
+	if err != nil {
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return nil, nil
 	}
+	return &msg, nil
 }
 
-// peerWriter sends messages to the peer
+// peerWriter sends messages to the peer, translating to the JSON-RPC 2.0
+// envelope when the peer negotiated that protocol.
 func peerWriter(peer *Peer) {
 	for msg := range peer.SendChan {
 		peer.mu.Lock()
-		err := peer.Conn.WriteJSON(msg)
+		var err error
+		if peer.Protocol == "jsonrpc" {
+			err = writeRPCMessage(peer.Conn, msg)
+		} else {
+			err = peer.Conn.WriteJSON(msg)
+		}
 		peer.mu.Unlock()
 		if err != nil {
 			log.Printf("Write error to peer %s: %v", peer.ID, err)
@@ -398,124 +611,180 @@ func handleSignalMessage(peer *Peer, msg SignalMessage) {
 		handleAnswer(peer, msg)
 	case MsgTypeICE:
 		handleICE(peer, msg)
+	case MsgTypeRequestOffer:
+		handleRequestOffer(peer, msg)
+	case MsgTypeRelayRequest:
+		handleRelayRequest(peer, msg)
 	default:
 		log.Printf("Unknown message type: %s", msg.Type)
 	}
 }
 
-// handleJoin handles join requests
+// handleJoin handles join requests. Sessions are now created exclusively
+// via POST /api/session, which mints the senderToken/receiverToken the
+// caller must present here — a session ID alone is no longer enough to
+// join one.
 func handleJoin(peer *Peer, msg SignalMessage) {
 	var payload struct {
 		SessionID string `json:"sessionId"`
 		Role      string `json:"role"`
+		Token     string `json:"token"`
 	}
 
 	if err := json.Unmarshal(msg.Payload, &payload); err != nil {
-		sendError(peer, "Invalid join payload")
+		sendCorrelatedError(peer, msg.RPCID, "Invalid join payload")
+		return
+	}
+	if payload.SessionID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
+		return
+	}
+	if payload.Token == "" {
+		sendCorrelatedError(peer, msg.RPCID, "Join token required")
 		return
 	}
 
 	peer.Role = payload.Role
 	sessionID := payload.SessionID
 
-	// If sender and no sessionID, create new session
-	if peer.Role == "sender" && sessionID == "" {
-		sessionID = sessionManager.CreateSession()
+	// Only the signature/expiry/session/role match are checked up front;
+	// the token is spent (ConsumeToken) only once AddPeer below actually
+	// claims the slot, so a benign claim failure (room full, sender slot
+	// race) doesn't burn the client's single-use capability for nothing.
+	parsedToken, err := checkJoinToken(payload.Token, sessionID, peer.Role)
+	if err != nil {
+		sendCorrelatedError(peer, msg.RPCID, "Invalid join token: "+err.Error())
+		return
 	}
 
 	peer.SessionID = sessionID
 
-	// Create Pion peer connection for this peer
-	peerConnection, err := createPeerConnection(peer)
-	if err != nil {
-		sendError(peer, "Failed to create peer connection: "+err.Error())
-		return
+	// Receivers get a single PeerConnection to the sender now; the sender's
+	// per-receiver connections are created lazily as each pairing negotiates
+	// (see senderPeerConnection), since a room can hold several receivers.
+	if peer.Role != "sender" {
+		peerConnection, err := createPeerConnection(peer, "")
+		if err != nil {
+			sendCorrelatedError(peer, msg.RPCID, "Failed to create peer connection: "+err.Error())
+			return
+		}
+		peer.PeerConnection = peerConnection
 	}
-	peer.PeerConnection = peerConnection
 
 	// Add peer to session
-	err = sessionManager.AddPeer(sessionID, peer)
-	if err != nil {
-		peerConnection.Close()
-		sendError(peer, err.Error())
+	if err := sessionManager.AddPeer(sessionID, peer); err != nil {
+		if peer.PeerConnection != nil {
+			peer.PeerConnection.Close()
+		}
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
 		return
 	}
 
-	// Send ready message with session info
+	// The slot claim succeeded, so this token is now spent. A non-nil error
+	// here, or alreadyUsed (a concurrent replay of the same token won the
+	// race), means the claim above shouldn't stand, so it's rolled back.
+	if alreadyUsed, err := sessionManager.store.ConsumeToken(sessionID, parsedToken.Signature); err != nil || alreadyUsed {
+		sessionManager.RemovePeer(sessionID, peer.ID)
+		if err != nil {
+			sendCorrelatedError(peer, msg.RPCID, "Error validating join token: "+err.Error())
+		} else {
+			sendCorrelatedError(peer, msg.RPCID, "Invalid join token: token already used")
+		}
+		return
+	}
+
+	// Send ready message with session info; carries msg.RPCID so a jsonrpc
+	// "join" call resolves with this as its result instead of an unrelated notification.
+	readyPayload, _ := json.Marshal(map[string]string{"sessionId": sessionID, "peerId": peer.ID})
 	peer.SendChan <- SignalMessage{
 		Type:      MsgTypeReady,
 		SessionID: sessionID,
 		PeerID:    peer.ID,
+		Payload:   readyPayload,
+		RPCID:     msg.RPCID,
 	}
 
-	// Check if both peers are connected
-	session, _ := sessionManager.GetSession(sessionID)
-	session.mu.RLock()
-	bothConnected := session.Sender != nil && session.Receiver != nil
-	var senderPeer *Peer
-	if session.Sender != nil {
-		senderPeer = session.Sender
-	}
-	session.mu.RUnlock()
-
-	if bothConnected {
-		log.Printf("🎉 Both peers connected to session: %s", sessionID)
-		// Notify sender that receiver has joined - sender should initiate WebRTC offer
-		if peer.Role == "receiver" && senderPeer != nil {
-			log.Printf("📡 Notifying sender that receiver joined session: %s", sessionID)
-			senderPeer.SendChan <- SignalMessage{
-				Type:      MsgTypePeerJoined,
-				SessionID: sessionID,
-			}
-		}
-	}
+	// Notifying the sender that a receiver joined (if one is already
+	// present, local to this pod or not) is handled by watchSession reacting
+	// to the EventPeerJoined the Claim above just published.
 }
 
-// handleOffer handles WebRTC offer from sender (relay to receiver)
+// handleOffer handles a WebRTC offer from the sender, targeted at one
+// specific receiver (relayed onward to that receiver)
 func handleOffer(peer *Peer, msg SignalMessage) {
 	if msg.SessionID == "" {
-		sendError(peer, "Session ID required")
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
+		return
+	}
+	if msg.TargetPeerID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "targetPeerId required for offer")
 		return
 	}
 
-	log.Printf("📡 Processing offer in session %s from peer %s", msg.SessionID, peer.ID)
+	log.Printf("📡 Processing offer in session %s from peer %s to %s", msg.SessionID, peer.ID, msg.TargetPeerID)
+
+	// If relay mode kicked in for this pairing, the server answers this
+	// offer itself instead of forwarding it to the receiver.
+	if relay, ok := sessionManager.GetRelay(msg.SessionID, msg.TargetPeerID); ok {
+		if err := handleRelayOfferFromSender(relay, msg); err != nil {
+			sendCorrelatedError(peer, msg.RPCID, "Relay offer error: "+err.Error())
+			return
+		}
+		ackRPC(peer, msg.RPCID)
+		return
+	}
 
 	// Parse the offer SDP
 	var offerSDP webrtc.SessionDescription
 	if err := json.Unmarshal(msg.Payload, &offerSDP); err != nil {
-		sendError(peer, "Invalid offer SDP")
+		sendCorrelatedError(peer, msg.RPCID, "Invalid offer SDP")
 		return
 	}
 
-	// Set the remote description on the sender's peer connection (for tracking)
-	if peer.PeerConnection != nil {
-		err := peer.PeerConnection.SetRemoteDescription(offerSDP)
-		if err != nil {
-			log.Printf("Warning: Could not set remote description on sender: %v", err)
-		}
+	// Set the remote description on this pairing's dedicated peer connection
+	// (for tracking); each receiver gets its own on the sender side.
+	pc, err := senderPeerConnection(peer, msg.TargetPeerID)
+	if err != nil {
+		sendCorrelatedError(peer, msg.RPCID, "Failed to create peer connection: "+err.Error())
+		return
+	}
+	if err := pc.SetRemoteDescription(offerSDP); err != nil {
+		log.Printf("Warning: Could not set remote description on sender: %v", err)
 	}
 
-	// Relay the offer to the receiver
-	err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg)
-	if err != nil {
+	// Relay the offer to the targeted receiver
+	if err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg); err != nil {
 		log.Printf("Relay error: %s", err.Error())
-		sendError(peer, err.Error())
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return
 	}
+	ackRPC(peer, msg.RPCID)
 }
 
 // handleAnswer handles WebRTC answer from receiver (relay to sender)
 func handleAnswer(peer *Peer, msg SignalMessage) {
 	if msg.SessionID == "" {
-		sendError(peer, "Session ID required")
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
 		return
 	}
 
 	log.Printf("📡 Processing answer in session %s from peer %s", msg.SessionID, peer.ID)
 
+	// If relay mode kicked in for this pairing, this answer is to the
+	// server's own offer and isn't forwarded anywhere further.
+	if relay, ok := sessionManager.GetRelay(msg.SessionID, peer.ID); ok {
+		if err := handleRelayAnswerFromReceiver(relay, msg); err != nil {
+			sendCorrelatedError(peer, msg.RPCID, "Relay answer error: "+err.Error())
+			return
+		}
+		ackRPC(peer, msg.RPCID)
+		return
+	}
+
 	// Parse the answer SDP
 	var answerSDP webrtc.SessionDescription
 	if err := json.Unmarshal(msg.Payload, &answerSDP); err != nil {
-		sendError(peer, "Invalid answer SDP")
+		sendCorrelatedError(peer, msg.RPCID, "Invalid answer SDP")
 		return
 	}
 
@@ -531,52 +800,116 @@ func handleAnswer(peer *Peer, msg SignalMessage) {
 	err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg)
 	if err != nil {
 		log.Printf("Relay error: %s", err.Error())
-		sendError(peer, err.Error())
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return
 	}
+	ackRPC(peer, msg.RPCID)
 }
 
-// handleICE handles ICE candidates
+// handleICE handles ICE candidates from either side of a pairing
 func handleICE(peer *Peer, msg SignalMessage) {
 	if msg.SessionID == "" {
-		sendError(peer, "Session ID required")
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
+		return
+	}
+	if peer.Role == "sender" && msg.TargetPeerID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "targetPeerId required for ICE from sender")
 		return
 	}
 
 	log.Printf("🧊 Processing ICE candidate in session %s from peer %s", msg.SessionID, peer.ID)
 
+	// In relay mode, this candidate belongs to one of the relay's own
+	// PeerConnections and isn't forwarded to the other real peer.
+	if relay, ok := sessionManager.GetRelay(msg.SessionID, relayReceiverID(peer, msg)); ok {
+		if err := handleRelayICE(relay, peer, msg); err != nil {
+			log.Printf("Warning: relay ICE error: %v", err)
+		}
+		ackRPC(peer, msg.RPCID)
+		return
+	}
+
 	// Parse the ICE candidate
 	var iceCandidate webrtc.ICECandidateInit
 	if err := json.Unmarshal(msg.Payload, &iceCandidate); err != nil {
-		sendError(peer, "Invalid ICE candidate")
+		sendCorrelatedError(peer, msg.RPCID, "Invalid ICE candidate")
 		return
 	}
 
-	// Add ICE candidate to the peer's connection (for server-side tracking)
-	if peer.PeerConnection != nil && peer.PeerConnection.RemoteDescription() != nil {
-		err := peer.PeerConnection.AddICECandidate(iceCandidate)
-		if err != nil {
+	// Resolve which PeerConnection this candidate belongs to: the receiver's
+	// single connection, or the sender's connection for this pairing.
+	pc := peer.PeerConnection
+	if peer.Role == "sender" {
+		peer.mu.Lock()
+		pc = peer.SenderConnections[msg.TargetPeerID]
+		peer.mu.Unlock()
+	}
+
+	// Add ICE candidate to the peer connection (for server-side tracking)
+	if pc != nil && pc.RemoteDescription() != nil {
+		if err := pc.AddICECandidate(iceCandidate); err != nil {
 			log.Printf("Warning: Could not add ICE candidate: %v", err)
 		}
 	}
 
 	// Relay ICE candidate to the other peer
-	err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg)
-	if err != nil {
+	if err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg); err != nil {
 		log.Printf("ICE relay error: %s", err.Error())
-		sendError(peer, err.Error())
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return
+	}
+	ackRPC(peer, msg.RPCID)
+}
+
+// handleRequestOffer lets a receiver that joined late (or lost its
+// connection) ask the sender to negotiate a fresh offer targeted at it.
+func handleRequestOffer(peer *Peer, msg SignalMessage) {
+	if msg.SessionID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
+		return
+	}
+
+	log.Printf("📨 Peer %s requested a fresh offer in session %s", peer.ID, msg.SessionID)
+
+	// BroadcastToSession fills in TargetPeerID with this receiver's ID so
+	// the sender knows which pairing to (re)negotiate.
+	if err := sessionManager.BroadcastToSession(msg.SessionID, peer.ID, msg); err != nil {
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return
 	}
+	ackRPC(peer, msg.RPCID)
 }
 
-// sendError sends an error message to a peer
+// sendError sends an error message to a peer with no request to correlate it to.
 func sendError(peer *Peer, message string) {
+	sendCorrelatedError(peer, nil, message)
+}
+
+// sendCorrelatedError sends an error message to a peer, threading the
+// originating request's RPCID (if any) so jsonrpc clients see the error as
+// the response to their call rather than an unrelated event.
+func sendCorrelatedError(peer *Peer, rpcID json.RawMessage, message string) {
 	errorPayload, _ := json.Marshal(map[string]string{"message": message})
 	peer.SendChan <- SignalMessage{
 		Type:    MsgTypeError,
 		Payload: json.RawMessage(errorPayload),
+		RPCID:   rpcID,
 	}
 }
 
-// handleCreateSession creates a new session via HTTP
+// ackRPC sends an empty success result for jsonrpc calls that have no
+// meaningful payload to return (offer/answer/trickle relays). It is a no-op
+// for legacy peers since rpcID is nil in that case.
+func ackRPC(peer *Peer, rpcID json.RawMessage) {
+	if rpcID == nil {
+		return
+	}
+	peer.SendChan <- SignalMessage{RPCID: rpcID, Payload: json.RawMessage(`{"relayed":true}`)}
+}
+
+// handleCreateSession creates a new session via HTTP and issues its signed
+// join tokens. receiverToken, not the bare sessionId, is what the sender is
+// meant to actually share out-of-band (QR/link) to invite a receiver.
 func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	if r.Method != http.MethodPost {
 		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
@@ -584,10 +917,59 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 	}
 
 	sessionID := sessionManager.CreateSession()
+	expiry := time.Now().Add(joinTokenTTL)
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(map[string]string{
+		"sessionId":     sessionID,
+		"senderToken":   signJoinToken(sessionID, "sender", expiry),
+		"receiverToken": signJoinToken(sessionID, "receiver", expiry),
+	})
+}
 
+// handleRotateReceiverToken issues a fresh receiver token for an in-flight
+// session without tearing down the transfer, so a legitimate receiver can
+// still join after a share link leaked. Previously issued receiver tokens
+// are not invalidated: an old token that hasn't been used yet can still
+// join until it expires.
+// Knowing the bare session ID isn't enough to call this — the caller must
+// prove they hold the session's senderToken, the same capability that was
+// required to join as its sender in the first place.
+func handleRotateReceiverToken(w http.ResponseWriter, r *http.Request) {
+	if r.Method != http.MethodPost {
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+
+	sessionID := strings.TrimSuffix(strings.TrimPrefix(r.URL.Path, "/api/session/"), "/rotate-receiver-token")
+	if sessionID == "" || sessionID == r.URL.Path {
+		http.Error(w, "Session ID required", http.StatusBadRequest)
+		return
+	}
+
+	var body struct {
+		SenderToken string `json:"senderToken"`
+	}
+	if err := json.NewDecoder(r.Body).Decode(&body); err != nil || body.SenderToken == "" {
+		http.Error(w, "senderToken required", http.StatusBadRequest)
+		return
+	}
+	// Checked, not consumed: rotating is an ownership check, not a join, so
+	// the sender can call this any number of times on the same senderToken.
+	if _, err := checkJoinToken(body.SenderToken, sessionID, "sender"); err != nil {
+		http.Error(w, "Invalid sender token: "+err.Error(), http.StatusUnauthorized)
+		return
+	}
+
+	if _, exists, err := sessionManager.store.Get(sessionID); err != nil || !exists {
+		http.Error(w, "Session not found", http.StatusNotFound)
+		return
+	}
+
+	expiry := time.Now().Add(joinTokenTTL)
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]string{
-		"sessionId": sessionID,
+		"receiverToken": signJoinToken(sessionID, "receiver", expiry),
 	})
 }
 
@@ -595,15 +977,45 @@ func handleCreateSession(w http.ResponseWriter, r *http.Request) {
 func handleHealth(w http.ResponseWriter, r *http.Request) {
 	w.Header().Set("Content-Type", "application/json")
 	json.NewEncoder(w).Encode(map[string]interface{}{
-		"status":  "healthy",
-		"server":  "Pion WebRTC Signaling Server",
-		"version": "1.0.0",
-		"webrtc":  "pion/webrtc v4",
+		"status":       "healthy",
+		"server":       "Pion WebRTC Signaling Server",
+		"version":      "1.0.0",
+		"webrtc":       "pion/webrtc v4",
+		"bytesRelayed": atomic.LoadUint64(&totalBytesRelayed),
 	})
 }
 
 func main() {
-	sessionManager = NewSessionManager()
+	iceConfigPath := flag.String("ice-config", "ice.json", "Path to a JSON file listing ICE (STUN/TURN) servers; falls back to public Google STUN if missing")
+	storeKind := flag.String("store", "memory", `Session store backend: "memory" (single process) or "redis" (horizontally scalable)`)
+	redisURL := flag.String("redis-url", "redis://localhost:6379/0", "Redis connection URL, used when -store=redis")
+	secretFlag := flag.String("secret", "", "HMAC signing secret for session join tokens (falls back to VAULT_JOIN_SECRET env var)")
+	flag.Parse()
+
+	joinSecret = resolveJoinSecret(*secretFlag)
+
+	iceMgr = newICEManager(*iceConfigPath)
+	if err := iceMgr.reload(); err != nil {
+		log.Printf("⚠️  %v (using default STUN servers)", err)
+	}
+	go watchICEConfigReload()
+
+	var store SessionStore
+	switch *storeKind {
+	case "redis":
+		redisStore, err := NewRedisSessionStore(*redisURL)
+		if err != nil {
+			log.Fatalf("❌ Could not connect to Redis session store: %v", err)
+		}
+		store = redisStore
+		log.Printf("🗄️  Using Redis session store at %s", *redisURL)
+	case "memory":
+		store = NewMemorySessionStore()
+	default:
+		log.Fatalf("❌ Unknown -store %q (want \"memory\" or \"redis\")", *storeKind)
+	}
+
+	sessionManager = NewSessionManager(store)
 
 	// Serve static files
 	fs := http.FileServer(http.Dir("../frontend"))
@@ -611,7 +1023,10 @@ func main() {
 
 	// API endpoints
 	http.HandleFunc("/ws", handleWebSocket)
+	http.HandleFunc("/ws/jsonrpc", handleWebSocket)
 	http.HandleFunc("/api/session", handleCreateSession)
+	http.HandleFunc("/api/session/", handleRotateReceiverToken)
+	http.HandleFunc("/api/ice", handleICEConfig)
 	http.HandleFunc("/api/health", handleHealth)
 
 	port := ":8080"
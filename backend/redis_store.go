@@ -0,0 +1,279 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+)
+
+// sessionTTL is how long a session's Redis hash survives without being
+// refreshed by a Claim, replacing MemorySessionStore's cleanup ticker with
+// Redis's own key expiry.
+const sessionTTL = 30 * time.Minute
+
+// RedisSessionStore is the horizontally-scalable SessionStore: session
+// topology lives in a Redis hash with a TTL instead of an in-process map,
+// so every signaling pod behind the load balancer sees the same claims, and
+// messages for a peer hosted on another pod are forwarded over Redis
+// pub/sub instead of a local channel.
+type RedisSessionStore struct {
+	client *redis.Client
+	ctx    context.Context
+}
+
+// NewRedisSessionStore connects to the Redis instance at url (e.g.
+// "redis://localhost:6379/0"), enables keyspace notifications so session
+// expiry is visible cluster-wide, and starts watching for it.
+func NewRedisSessionStore(url string) (*RedisSessionStore, error) {
+	opts, err := redis.ParseURL(url)
+	if err != nil {
+		return nil, fmt.Errorf("parsing redis url: %w", err)
+	}
+
+	client := redis.NewClient(opts)
+	ctx := context.Background()
+	if err := client.Ping(ctx).Err(); err != nil {
+		return nil, fmt.Errorf("connecting to redis: %w", err)
+	}
+
+	store := &RedisSessionStore{client: client, ctx: ctx}
+
+	// "Ex" = keyevent notifications (the __keyevent@<db>__ channels) for
+	// expired events only, the minimum needed for watchExpirations below.
+	if err := client.ConfigSet(ctx, "notify-keyspace-events", "Ex").Err(); err != nil {
+		log.Printf("⚠️  Could not enable Redis keyspace notifications (expiry events won't be seen): %v", err)
+	}
+	go store.watchExpirations()
+
+	return store, nil
+}
+
+func sessionKey(sessionID string) string     { return "vault:session:" + sessionID }
+func eventsChannel(sessionID string) string { return "vault:events:" + sessionID }
+func msgChannel(sessionID string) string    { return "vault:messages:" + sessionID }
+
+func (s *RedisSessionStore) Create() (string, error) {
+	sessionID := generateSessionID()
+	key := sessionKey(sessionID)
+	if err := s.client.HSet(s.ctx, key, "created", time.Now().Unix()).Err(); err != nil {
+		return "", err
+	}
+	if err := s.client.Expire(s.ctx, key, sessionTTL).Err(); err != nil {
+		return "", err
+	}
+	return sessionID, nil
+}
+
+func (s *RedisSessionStore) Get(sessionID string) (SessionMeta, bool, error) {
+	vals, err := s.client.HGetAll(s.ctx, sessionKey(sessionID)).Result()
+	if err != nil {
+		return SessionMeta{}, false, err
+	}
+	if len(vals) == 0 {
+		return SessionMeta{}, false, nil
+	}
+
+	meta := SessionMeta{SenderID: vals["sender"]}
+	if receivers := vals["receivers"]; receivers != "" {
+		meta.ReceiverIDs = strings.Split(receivers, ",")
+	}
+	return meta, true, nil
+}
+
+func (s *RedisSessionStore) Delete(sessionID string) error {
+	return s.client.Del(s.ctx, sessionKey(sessionID)).Err()
+}
+
+// Claim uses a WATCH/MULTI transaction so two pods racing to take the
+// sender slot (or the last receiver slot) for the same session can't both
+// succeed.
+func (s *RedisSessionStore) Claim(sessionID, role, peerID string) error {
+	key := sessionKey(sessionID)
+
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		vals, err := tx.HGetAll(s.ctx, key).Result()
+		if err != nil {
+			return err
+		}
+		if len(vals) == 0 {
+			return &ErrorResponse{Message: "Session not found"}
+		}
+
+		var receivers []string
+		if r := vals["receivers"]; r != "" {
+			receivers = strings.Split(r, ",")
+		}
+
+		if role == "sender" {
+			if vals["sender"] != "" {
+				return &ErrorResponse{Message: "Sender already connected"}
+			}
+		} else {
+			if len(receivers) >= MaxReceiversPerSession {
+				return &ErrorResponse{Message: "Session already has the maximum number of receivers"}
+			}
+			receivers = append(receivers, peerID)
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			if role == "sender" {
+				pipe.HSet(s.ctx, key, "sender", peerID)
+			} else {
+				pipe.HSet(s.ctx, key, "receivers", strings.Join(receivers, ","))
+			}
+			pipe.Expire(s.ctx, key, sessionTTL)
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	return s.publishEvent(sessionID, Event{Type: EventPeerJoined, Role: role, PeerID: peerID})
+}
+
+func (s *RedisSessionStore) Unclaim(sessionID, peerID string) error {
+	key := sessionKey(sessionID)
+	var releasedRole string
+
+	err := s.client.Watch(s.ctx, func(tx *redis.Tx) error {
+		vals, err := tx.HGetAll(s.ctx, key).Result()
+		if err != nil || len(vals) == 0 {
+			return err
+		}
+
+		_, err = tx.TxPipelined(s.ctx, func(pipe redis.Pipeliner) error {
+			if vals["sender"] == peerID {
+				pipe.HSet(s.ctx, key, "sender", "")
+				releasedRole = "sender"
+			}
+			if receivers := vals["receivers"]; receivers != "" {
+				ids := strings.Split(receivers, ",")
+				for i, id := range ids {
+					if id == peerID {
+						ids = append(ids[:i], ids[i+1:]...)
+						pipe.HSet(s.ctx, key, "receivers", strings.Join(ids, ","))
+						releasedRole = "receiver"
+						break
+					}
+				}
+			}
+			return nil
+		})
+		return err
+	}, key)
+	if err != nil {
+		return err
+	}
+
+	if releasedRole == "" {
+		return nil
+	}
+	return s.publishEvent(sessionID, Event{Type: EventPeerLeft, Role: releasedRole, PeerID: peerID})
+}
+
+func (s *RedisSessionStore) publishEvent(sessionID string, evt Event) error {
+	payload, err := json.Marshal(evt)
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(s.ctx, eventsChannel(sessionID), payload).Err()
+}
+
+func (s *RedisSessionStore) WatchPeerJoined(sessionID string) (<-chan Event, func()) {
+	sub := s.client.Subscribe(s.ctx, eventsChannel(sessionID))
+	out := make(chan Event, 8)
+
+	go func() {
+		defer close(out)
+		for msg := range sub.Channel() {
+			var evt Event
+			if err := json.Unmarshal([]byte(msg.Payload), &evt); err != nil {
+				log.Printf("Error decoding session event: %v", err)
+				continue
+			}
+			select {
+			case out <- evt:
+			default:
+			}
+		}
+	}()
+
+	return out, func() { sub.Close() }
+}
+
+func (s *RedisSessionStore) Publish(sessionID, deliverTo string, msg SignalMessage) error {
+	payload, err := json.Marshal(DeliveryEnvelope{DeliverTo: deliverTo, Message: msg})
+	if err != nil {
+		return err
+	}
+	return s.client.Publish(s.ctx, msgChannel(sessionID), payload).Err()
+}
+
+func (s *RedisSessionStore) Subscribe(sessionID string) (<-chan DeliveryEnvelope, func()) {
+	sub := s.client.Subscribe(s.ctx, msgChannel(sessionID))
+	out := make(chan DeliveryEnvelope, 32)
+
+	go func() {
+		defer close(out)
+		for m := range sub.Channel() {
+			var env DeliveryEnvelope
+			if err := json.Unmarshal([]byte(m.Payload), &env); err != nil {
+				log.Printf("Error decoding relayed message: %v", err)
+				continue
+			}
+			select {
+			case out <- env:
+			default:
+			}
+		}
+	}()
+
+	return out, func() { sub.Close() }
+}
+
+// tokenKey namespaces a consumed join token by session and signature; it's
+// given the same TTL as a join token's lifetime so the "used" record
+// doesn't outlive the token it's tracking.
+func tokenKey(sessionID, tokenSig string) string {
+	return "vault:token:" + sessionID + ":" + tokenSig
+}
+
+// ConsumeToken uses SETNX so two pods racing to redeem the same (replayed)
+// token can't both win.
+func (s *RedisSessionStore) ConsumeToken(sessionID, tokenSig string) (bool, error) {
+	key := tokenKey(sessionID, tokenSig)
+	set, err := s.client.SetNX(s.ctx, key, 1, joinTokenTTL).Result()
+	if err != nil {
+		return false, err
+	}
+	return !set, nil
+}
+
+// watchExpirations listens for Redis key-expiry keyspace notifications on
+// session hashes and republishes them as an EventExpired, the cluster-wide
+// equivalent of MemorySessionStore's cleanup ticker telling a session's own
+// pod that it timed out.
+func (s *RedisSessionStore) watchExpirations() {
+	sub := s.client.PSubscribe(s.ctx, "__keyevent@*__:expired")
+	defer sub.Close()
+
+	for msg := range sub.Channel() {
+		key := msg.Payload
+		if !strings.HasPrefix(key, "vault:session:") {
+			continue
+		}
+		sessionID := strings.TrimPrefix(key, "vault:session:")
+		if err := s.publishEvent(sessionID, Event{Type: EventExpired}); err != nil {
+			log.Printf("Error publishing expiry for session %s: %v", sessionID, err)
+			continue
+		}
+		log.Printf("⏰ Expired session (redis TTL): %s", sessionID)
+	}
+}
@@ -0,0 +1,468 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/pion/webrtc/v4"
+)
+
+const (
+	// iceFailureRelayDelay is how long a pairing's ICE connection state must
+	// stay Failed/Disconnected before the server automatically falls back to
+	// relaying bytes itself instead of leaving the transfer for dead.
+	iceFailureRelayDelay = 5 * time.Second
+
+	// DataChannel backpressure thresholds for the relay bridge: once a leg's
+	// buffered amount crosses the high mark, forwarding onto it pauses and
+	// queues until its OnBufferedAmountLow fires at the low threshold.
+	relayBufferedAmountLowThreshold  = 512 * 1024
+	relayBufferedAmountHighWaterMark = 4 * 1024 * 1024
+
+	// relayQueueMaxBytes bounds how much data forward will hold for a
+	// congested leg before giving up and surfacing an error, so a peer that
+	// never drains (gone, crashed) can't grow the relay's memory use
+	// without bound. Legitimate congestion drains well within this via
+	// OnBufferedAmountLow long before ever getting close.
+	relayQueueMaxBytes = 16 * 1024 * 1024
+)
+
+// totalBytesRelayed is a process-wide counter of bytes pushed through the
+// relay fallback, so operators can see how much traffic is failing over to
+// server relay instead of going direct peer-to-peer.
+var totalBytesRelayed uint64
+
+// relayDataChannel is the subset of *webrtc.DataChannel the backpressure
+// logic in forward needs; factored out so that logic can be unit tested
+// against a fake instead of a real PeerConnection's DataChannel.
+type relayDataChannel interface {
+	BufferedAmount() uint64
+	Send(data []byte) error
+}
+
+// Relay bridges one sender/receiver pairing through the server when direct
+// P2P ICE connectivity fails between their browsers. SenderPC stands in for
+// the receiver from the real sender's point of view (it answers the real
+// sender's offer); ReceiverPC stands in for the sender from the real
+// receiver's point of view (it sends the real receiver an offer). Bytes
+// arriving on one leg's DataChannel are forwarded to the other leg.
+type Relay struct {
+	SessionID  string
+	ReceiverID string
+	SenderPC   *webrtc.PeerConnection
+	ReceiverPC *webrtc.PeerConnection
+
+	mu             sync.Mutex
+	senderDC       relayDataChannel
+	receiverDC     relayDataChannel
+	senderPaused   bool
+	receiverPaused bool
+	// senderQueue holds data waiting to be sent on senderDC while
+	// senderPaused is true; receiverQueue is receiverDC's counterpart.
+	senderQueue   [][]byte
+	receiverQueue [][]byte
+}
+
+// GetRelay returns the active relay for a session/receiver pairing, if any.
+func (sm *SessionManager) GetRelay(sessionID, receiverID string) (*Relay, bool) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return nil, false
+	}
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	r, ok := session.Room.Relays[receiverID]
+	return r, ok
+}
+
+// StartRelay creates and wires up a Relay for sessionID/receiverID unless one
+// is already active, then asks the sender to (re)offer — the server will
+// answer that offer itself from now on instead of forwarding it. Sender and
+// receiver presence is checked against the store's cluster-wide topology,
+// not the pod-local Room, since the relay can be started from whichever
+// pod hosts the peer that asked for it while the other side of the pairing
+// is connected to a different pod entirely.
+func (sm *SessionManager) StartRelay(sessionID, receiverID string) (*Relay, error) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return nil, &ErrorResponse{Message: "Session not found"}
+	}
+
+	meta, metaExists, err := sm.store.Get(sessionID)
+	if err != nil {
+		return nil, err
+	}
+	if !metaExists || meta.SenderID == "" || !containsPeerID(meta.ReceiverIDs, receiverID) {
+		return nil, &ErrorResponse{Message: "Cannot start relay without both sender and receiver present"}
+	}
+	senderID := meta.SenderID
+
+	session.mu.Lock()
+	if session.Room.Relays == nil {
+		session.Room.Relays = make(map[string]*Relay)
+	}
+	if r, ok := session.Room.Relays[receiverID]; ok {
+		session.mu.Unlock()
+		return r, nil
+	}
+
+	senderPC, err := webrtc.NewPeerConnection(currentWebRTCConfig())
+	if err != nil {
+		session.mu.Unlock()
+		return nil, err
+	}
+	receiverPC, err := webrtc.NewPeerConnection(currentWebRTCConfig())
+	if err != nil {
+		senderPC.Close()
+		session.mu.Unlock()
+		return nil, err
+	}
+
+	relay := &Relay{SessionID: sessionID, ReceiverID: receiverID, SenderPC: senderPC, ReceiverPC: receiverPC}
+
+	senderPC.OnICECandidate(func(c *webrtc.ICECandidate) {
+		relayForwardICE(c, sessionID, senderID)
+	})
+	receiverPC.OnICECandidate(func(c *webrtc.ICECandidate) {
+		relayForwardICE(c, sessionID, receiverID)
+	})
+	senderPC.OnDataChannel(func(dc *webrtc.DataChannel) {
+		relay.mu.Lock()
+		relay.senderDC = dc
+		relay.mu.Unlock()
+		relay.wireLeg(dc, relay.forwardToReceiver, &relay.senderPaused, &relay.senderQueue)
+	})
+
+	session.Room.Relays[receiverID] = relay
+	session.mu.Unlock()
+
+	log.Printf("🔁 Relay started for session %s, receiver %s", sessionID, receiverID)
+
+	// Negotiate the receiver leg: the server creates the DataChannel and
+	// offers, standing in for the real sender.
+	dc, err := receiverPC.CreateDataChannel("file-transfer", nil)
+	if err != nil {
+		log.Printf("Relay error creating receiver data channel: %v", err)
+	} else {
+		relay.mu.Lock()
+		relay.receiverDC = dc
+		relay.mu.Unlock()
+		relay.wireLeg(dc, relay.forwardToSender, &relay.receiverPaused, &relay.receiverQueue)
+	}
+	if err := relayOfferToReceiver(receiverPC, sessionID, receiverID); err != nil {
+		log.Printf("Relay error offering to receiver %s: %v", receiverID, err)
+	}
+
+	// Negotiate the sender leg: ask the sender to (re)send an offer targeted
+	// at this receiver, which handleOffer now routes to the relay instead of
+	// forwarding it. Routed through the store like any other signaling
+	// message, since the sender may be hosted on a different pod than the
+	// one running this relay.
+	requestOffer := SignalMessage{Type: MsgTypeRequestOffer, SessionID: sessionID, TargetPeerID: receiverID}
+	if err := sm.RelayToPeer(sessionID, senderID, requestOffer); err != nil {
+		log.Printf("Relay error requesting offer from sender %s: %v", senderID, err)
+	}
+
+	return relay, nil
+}
+
+// containsPeerID reports whether ids contains peerID.
+func containsPeerID(ids []string, peerID string) bool {
+	for _, id := range ids {
+		if id == peerID {
+			return true
+		}
+	}
+	return false
+}
+
+// StopRelay tears down and removes the relay for sessionID/receiverID, if any.
+func (sm *SessionManager) StopRelay(sessionID, receiverID string) {
+	session, exists := sm.GetSession(sessionID)
+	if !exists {
+		return
+	}
+	session.mu.Lock()
+	relay, ok := session.Room.Relays[receiverID]
+	if ok {
+		delete(session.Room.Relays, receiverID)
+	}
+	session.mu.Unlock()
+
+	if ok {
+		relay.close()
+	}
+}
+
+// stopRelayLocked is StopRelay's counterpart for callers that already hold
+// session.mu (RemovePeer, during shutdown) — it only removes the relay from
+// the room and hands the actual PeerConnection teardown to a separate
+// goroutine, so the caller doesn't run relay.close()'s logging and
+// I/O-adjacent work itself while holding the room's lock.
+func (sm *SessionManager) stopRelayLocked(session *Session, receiverID string) {
+	relay, ok := session.Room.Relays[receiverID]
+	if !ok {
+		return
+	}
+	delete(session.Room.Relays, receiverID)
+	go relay.close()
+}
+
+func (relay *Relay) close() {
+	relay.SenderPC.Close()
+	relay.ReceiverPC.Close()
+	log.Printf("🔁 Relay stopped for session %s, receiver %s", relay.SessionID, relay.ReceiverID)
+}
+
+// relayReceiverID resolves the receiver ID a relay for this message/peer
+// would be keyed by: the message's target when peer is the sender, or the
+// peer's own ID when peer is a receiver.
+func relayReceiverID(peer *Peer, msg SignalMessage) string {
+	if peer.Role == "sender" {
+		return msg.TargetPeerID
+	}
+	return peer.ID
+}
+
+// relayOfferToReceiver has the server's receiver-facing PeerConnection make
+// an offer and sends it directly to the receiver, bypassing the usual
+// sender-only relay path since the server is acting as the sender here.
+func relayOfferToReceiver(pc *webrtc.PeerConnection, sessionID, receiverID string) error {
+	offer, err := pc.CreateOffer(nil)
+	if err != nil {
+		return err
+	}
+	if err := pc.SetLocalDescription(offer); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(pc.LocalDescription())
+	if err != nil {
+		return err
+	}
+	msg := SignalMessage{Type: MsgTypeOffer, SessionID: sessionID, TargetPeerID: receiverID, Payload: payload}
+	return sessionManager.RelayToPeer(sessionID, receiverID, msg)
+}
+
+// relayForwardICE sends a locally-gathered ICE candidate from one of the
+// relay's own PeerConnections to the given real peer.
+func relayForwardICE(candidate *webrtc.ICECandidate, sessionID, targetPeerID string) {
+	if candidate == nil {
+		return
+	}
+	payload, err := json.Marshal(candidate.ToJSON())
+	if err != nil {
+		log.Printf("Error marshaling relay ICE candidate: %v", err)
+		return
+	}
+	msg := SignalMessage{Type: MsgTypeICE, SessionID: sessionID, TargetPeerID: targetPeerID, Payload: json.RawMessage(payload)}
+	if err := sessionManager.RelayToPeer(sessionID, targetPeerID, msg); err != nil {
+		log.Printf("Error sending relay ICE candidate: %v", err)
+	}
+}
+
+// handleRelayOfferFromSender answers the real sender's offer directly,
+// standing in for the receiver, instead of forwarding it onward.
+func handleRelayOfferFromSender(relay *Relay, msg SignalMessage) error {
+	var offerSDP webrtc.SessionDescription
+	if err := json.Unmarshal(msg.Payload, &offerSDP); err != nil {
+		return err
+	}
+	if err := relay.SenderPC.SetRemoteDescription(offerSDP); err != nil {
+		return err
+	}
+	answer, err := relay.SenderPC.CreateAnswer(nil)
+	if err != nil {
+		return err
+	}
+	if err := relay.SenderPC.SetLocalDescription(answer); err != nil {
+		return err
+	}
+	payload, err := json.Marshal(relay.SenderPC.LocalDescription())
+	if err != nil {
+		return err
+	}
+	answerMsg := SignalMessage{Type: MsgTypeAnswer, SessionID: msg.SessionID, TargetPeerID: relay.ReceiverID, Payload: payload}
+	return sessionManager.RelayToPeer(msg.SessionID, relay.senderPeerID(), answerMsg)
+}
+
+// senderPeerID looks the sender's peer ID back up through the session, since
+// Relay only tracks the receiver it's paired with.
+func (relay *Relay) senderPeerID() string {
+	session, exists := sessionManager.GetSession(relay.SessionID)
+	if !exists {
+		return ""
+	}
+	session.mu.RLock()
+	defer session.mu.RUnlock()
+	if session.Room.Sender == nil {
+		return ""
+	}
+	return session.Room.Sender.ID
+}
+
+// handleRelayAnswerFromReceiver applies the real receiver's answer to the
+// relay's receiver-facing PeerConnection; it isn't forwarded anywhere since
+// the server originated that offer itself.
+func handleRelayAnswerFromReceiver(relay *Relay, msg SignalMessage) error {
+	var answerSDP webrtc.SessionDescription
+	if err := json.Unmarshal(msg.Payload, &answerSDP); err != nil {
+		return err
+	}
+	return relay.ReceiverPC.SetRemoteDescription(answerSDP)
+}
+
+// handleRelayICE feeds an ICE candidate from a real peer into whichever of
+// the relay's PeerConnections corresponds to that peer.
+func handleRelayICE(relay *Relay, peer *Peer, msg SignalMessage) error {
+	var candidate webrtc.ICECandidateInit
+	if err := json.Unmarshal(msg.Payload, &candidate); err != nil {
+		return err
+	}
+	pc := relay.ReceiverPC
+	if peer.Role == "sender" {
+		pc = relay.SenderPC
+	}
+	if pc.RemoteDescription() == nil {
+		return nil
+	}
+	return pc.AddICECandidate(candidate)
+}
+
+// handleRelayRequest lets either side explicitly opt into relay mode (e.g.
+// after observing its own ICE connection fail) instead of waiting out
+// iceFailureRelayDelay.
+func handleRelayRequest(peer *Peer, msg SignalMessage) {
+	if msg.SessionID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "Session ID required")
+		return
+	}
+	receiverID := relayReceiverID(peer, msg)
+	if receiverID == "" {
+		sendCorrelatedError(peer, msg.RPCID, "targetPeerId required to relay from sender")
+		return
+	}
+
+	if _, err := sessionManager.StartRelay(msg.SessionID, receiverID); err != nil {
+		sendCorrelatedError(peer, msg.RPCID, err.Error())
+		return
+	}
+	ackRPC(peer, msg.RPCID)
+}
+
+// maybeAutoRelayOnICEFailure schedules an automatic relay fallback if the
+// pairing's ICE connection is still failed/disconnected after
+// iceFailureRelayDelay.
+func maybeAutoRelayOnICEFailure(peer *Peer, targetPeerID string) {
+	sessionID, receiverID := peer.SessionID, targetPeerID
+	if peer.Role != "sender" {
+		receiverID = peer.ID
+	}
+
+	time.AfterFunc(iceFailureRelayDelay, func() {
+		if _, exists := sessionManager.GetRelay(sessionID, receiverID); exists {
+			return
+		}
+		pc := peer.PeerConnection
+		if peer.Role == "sender" {
+			peer.mu.Lock()
+			pc = peer.SenderConnections[targetPeerID]
+			peer.mu.Unlock()
+		}
+		if pc == nil {
+			return
+		}
+		if state := pc.ICEConnectionState(); state != webrtc.ICEConnectionStateFailed && state != webrtc.ICEConnectionStateDisconnected {
+			return
+		}
+		log.Printf("⏱️  ICE still down for session %s receiver %s after %s, falling back to relay", sessionID, receiverID, iceFailureRelayDelay)
+		if _, err := sessionManager.StartRelay(sessionID, receiverID); err != nil {
+			log.Printf("Auto-relay failed for session %s receiver %s: %v", sessionID, receiverID, err)
+		}
+	})
+}
+
+// wireLeg wires dc's message handler to forward payloads to the other leg
+// via forward, and drains pausedFlag/queue — dc's own outbound backlog,
+// built up while the opposite leg's forward call found dc congested — once
+// dc's real OnBufferedAmountLow fires.
+func (relay *Relay) wireLeg(dc *webrtc.DataChannel, forward func([]byte) error, pausedFlag *bool, queue *[][]byte) {
+	dc.SetBufferedAmountLowThreshold(relayBufferedAmountLowThreshold)
+	dc.OnBufferedAmountLow(func() {
+		relay.mu.Lock()
+		defer relay.mu.Unlock()
+		if err := relay.flushLocked(dc, pausedFlag, queue); err != nil {
+			log.Printf("Relay flush error (session %s, receiver %s): %v", relay.SessionID, relay.ReceiverID, err)
+		}
+	})
+	dc.OnMessage(func(m webrtc.DataChannelMessage) {
+		if err := forward(m.Data); err != nil {
+			log.Printf("Relay forward error (session %s, receiver %s): %v", relay.SessionID, relay.ReceiverID, err)
+		}
+	})
+}
+
+func (relay *Relay) forwardToReceiver(data []byte) error {
+	return relay.forward(func() relayDataChannel { return relay.receiverDC }, &relay.receiverPaused, &relay.receiverQueue, data)
+}
+
+func (relay *Relay) forwardToSender(data []byte) error {
+	return relay.forward(func() relayDataChannel { return relay.senderDC }, &relay.senderPaused, &relay.senderQueue, data)
+}
+
+// forward queues data for the outbound DataChannel and flushes as much of
+// the queue as current backpressure allows. Once the leg's buffered amount
+// crosses relayBufferedAmountHighWaterMark, flushing pauses until dc's
+// OnBufferedAmountLow (wired in wireLeg) drains the backlog — data is
+// queued rather than dropped, so sustained congestion slows a transfer down
+// instead of silently truncating it. Data that would push the backlog past
+// relayQueueMaxBytes is not queued; forward returns an error instead.
+func (relay *Relay) forward(outbound func() relayDataChannel, pausedFlag *bool, queue *[][]byte, data []byte) error {
+	relay.mu.Lock()
+	defer relay.mu.Unlock()
+
+	dc := outbound()
+	if dc == nil {
+		return nil // other leg isn't connected yet
+	}
+
+	queuedBytes := 0
+	for _, chunk := range *queue {
+		queuedBytes += len(chunk)
+	}
+	if queuedBytes+len(data) > relayQueueMaxBytes {
+		return &ErrorResponse{Message: "relay backlog exceeded, other leg isn't draining"}
+	}
+	*queue = append(*queue, data)
+
+	return relay.flushLocked(dc, pausedFlag, queue)
+}
+
+// flushLocked sends as much of queue onto dc as current backpressure
+// allows; callers must hold relay.mu. Once paused, draining waits for
+// BufferedAmount to fall to relayBufferedAmountLowThreshold before
+// resuming, and re-pauses if a send pushes dc back above
+// relayBufferedAmountHighWaterMark, leaving the rest of the queue intact
+// for the next OnBufferedAmountLow.
+func (relay *Relay) flushLocked(dc relayDataChannel, pausedFlag *bool, queue *[][]byte) error {
+	if *pausedFlag && dc.BufferedAmount() > relayBufferedAmountLowThreshold {
+		return nil
+	}
+	*pausedFlag = false
+
+	for len(*queue) > 0 {
+		if dc.BufferedAmount() > relayBufferedAmountHighWaterMark {
+			*pausedFlag = true
+			return nil
+		}
+		next := (*queue)[0]
+		if err := dc.Send(next); err != nil {
+			return err
+		}
+		atomic.AddUint64(&totalBytesRelayed, uint64(len(next)))
+		*queue = (*queue)[1:]
+	}
+	return nil
+}
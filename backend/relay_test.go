@@ -0,0 +1,154 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// fakeDataChannel is a relayDataChannel test double whose buffered amount is
+// set directly, so the backpressure thresholds in (*Relay).forward/flushLocked
+// can be exercised without a real PeerConnection.
+type fakeDataChannel struct {
+	buffered uint64
+	sent     [][]byte
+	sendErr  error
+	// sendIncrement simulates a Send growing the real outbound backlog, so
+	// a test can make a drain re-congest partway through.
+	sendIncrement uint64
+}
+
+func (f *fakeDataChannel) BufferedAmount() uint64 { return f.buffered }
+
+func (f *fakeDataChannel) Send(data []byte) error {
+	if f.sendErr != nil {
+		return f.sendErr
+	}
+	f.sent = append(f.sent, data)
+	f.buffered += f.sendIncrement
+	return nil
+}
+
+func TestRelayForwardQueuesAtHighWaterMark(t *testing.T) {
+	relay := &Relay{}
+	dc := &fakeDataChannel{buffered: relayBufferedAmountHighWaterMark + 1}
+
+	if err := relay.forward(func() relayDataChannel { return dc }, &relay.senderPaused, &relay.senderQueue, []byte("a")); err != nil {
+		t.Fatalf("forward: unexpected error: %v", err)
+	}
+	if !relay.senderPaused {
+		t.Fatal("forward: expected senderPaused=true once buffered amount exceeds the high water mark")
+	}
+	if len(dc.sent) != 0 {
+		t.Fatalf("forward: expected no sends while pausing, but Send was called %d time(s)", len(dc.sent))
+	}
+	if len(relay.senderQueue) != 1 || string(relay.senderQueue[0]) != "a" {
+		t.Fatalf("forward: expected the data to be queued (not dropped), got queue=%v", relay.senderQueue)
+	}
+}
+
+func TestRelayForwardKeepsQueuingWhilePaused(t *testing.T) {
+	relay := &Relay{senderPaused: true}
+	dc := &fakeDataChannel{buffered: relayBufferedAmountHighWaterMark}
+
+	if err := relay.forward(func() relayDataChannel { return dc }, &relay.senderPaused, &relay.senderQueue, []byte("a")); err != nil {
+		t.Fatalf("forward: unexpected error: %v", err)
+	}
+	if !relay.senderPaused {
+		t.Fatal("forward: expected senderPaused to remain true above the low threshold")
+	}
+	if len(dc.sent) != 0 {
+		t.Fatalf("forward: expected no sends while paused, got %d", len(dc.sent))
+	}
+	if len(relay.senderQueue) != 1 {
+		t.Fatalf("forward: expected the data to be appended to the queue, got %v", relay.senderQueue)
+	}
+}
+
+func TestFlushLockedDrainsQueueAtLowThreshold(t *testing.T) {
+	relay := &Relay{senderPaused: true, senderQueue: [][]byte{[]byte("one"), []byte("two")}}
+	dc := &fakeDataChannel{buffered: relayBufferedAmountLowThreshold}
+
+	if err := relay.flushLocked(dc, &relay.senderPaused, &relay.senderQueue); err != nil {
+		t.Fatalf("flushLocked: unexpected error: %v", err)
+	}
+	if relay.senderPaused {
+		t.Fatal("flushLocked: expected senderPaused=false once buffered amount falls to the low threshold")
+	}
+	if len(dc.sent) != 2 || string(dc.sent[0]) != "one" || string(dc.sent[1]) != "two" {
+		t.Fatalf("flushLocked: expected the queued backlog to drain in order, got %v", dc.sent)
+	}
+	if len(relay.senderQueue) != 0 {
+		t.Fatalf("flushLocked: expected the queue to be empty after draining, got %v", relay.senderQueue)
+	}
+}
+
+func TestFlushLockedRepausesIfSendingReCongests(t *testing.T) {
+	relay := &Relay{senderPaused: true, senderQueue: [][]byte{[]byte("one"), []byte("two"), []byte("three")}}
+	dc := &fakeDataChannel{buffered: relayBufferedAmountLowThreshold, sendIncrement: relayBufferedAmountHighWaterMark}
+
+	if err := relay.flushLocked(dc, &relay.senderPaused, &relay.senderQueue); err != nil {
+		t.Fatalf("flushLocked: unexpected error: %v", err)
+	}
+	if !relay.senderPaused {
+		t.Fatal("flushLocked: expected senderPaused=true again once a send pushes buffered amount back above the high water mark")
+	}
+	if len(dc.sent) != 1 || string(dc.sent[0]) != "one" {
+		t.Fatalf("flushLocked: expected exactly one send before re-congesting, got %v", dc.sent)
+	}
+	if len(relay.senderQueue) != 2 || string(relay.senderQueue[0]) != "two" || string(relay.senderQueue[1]) != "three" {
+		t.Fatalf("flushLocked: expected the rest of the backlog to stay queued in order, got %v", relay.senderQueue)
+	}
+}
+
+func TestRelayForwardPassesThroughWhenNotCongested(t *testing.T) {
+	relay := &Relay{}
+	dc := &fakeDataChannel{buffered: 0}
+
+	if err := relay.forward(func() relayDataChannel { return dc }, &relay.senderPaused, &relay.senderQueue, []byte("hello")); err != nil {
+		t.Fatalf("forward: unexpected error: %v", err)
+	}
+	if relay.senderPaused {
+		t.Fatal("forward: expected senderPaused=false when nowhere near the thresholds")
+	}
+	if len(dc.sent) != 1 || string(dc.sent[0]) != "hello" {
+		t.Fatalf("forward: expected one send, got %v", dc.sent)
+	}
+	if len(relay.senderQueue) != 0 {
+		t.Fatalf("forward: expected nothing left queued, got %v", relay.senderQueue)
+	}
+}
+
+func TestRelayForwardNilChannelIsNoop(t *testing.T) {
+	relay := &Relay{}
+
+	if err := relay.forward(func() relayDataChannel { return nil }, &relay.senderPaused, &relay.senderQueue, []byte("a")); err != nil {
+		t.Fatalf("forward: expected nil error when the other leg isn't connected yet, got %v", err)
+	}
+	if len(relay.senderQueue) != 0 {
+		t.Fatalf("forward: expected nothing queued when there's no outbound channel yet, got %v", relay.senderQueue)
+	}
+}
+
+func TestRelayForwardPropagatesSendError(t *testing.T) {
+	relay := &Relay{}
+	wantErr := errors.New("send failed")
+	dc := &fakeDataChannel{buffered: 0, sendErr: wantErr}
+
+	if err := relay.forward(func() relayDataChannel { return dc }, &relay.senderPaused, &relay.senderQueue, []byte("a")); !errors.Is(err, wantErr) {
+		t.Fatalf("forward: expected Send's error to propagate, got %v", err)
+	}
+}
+
+func TestRelayForwardRejectsBacklogOverflow(t *testing.T) {
+	relay := &Relay{senderPaused: true, senderQueue: [][]byte{make([]byte, relayQueueMaxBytes)}}
+	dc := &fakeDataChannel{buffered: relayBufferedAmountHighWaterMark + 1}
+
+	err := relay.forward(func() relayDataChannel { return dc }, &relay.senderPaused, &relay.senderQueue, []byte("one more byte"))
+	if err == nil {
+		t.Fatal("forward: expected an error once the queued backlog exceeds relayQueueMaxBytes, got nil")
+	}
+	if !strings.Contains(err.Error(), "backlog") {
+		t.Fatalf("forward: expected a backlog-overflow error, got %v", err)
+	}
+}
@@ -0,0 +1,197 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/gorilla/websocket"
+	"github.com/pion/webrtc/v4"
+)
+
+// jsonRPCSubprotocol is the WebSocket subprotocol a client negotiates to opt
+// into JSON-RPC 2.0 framing on /ws (the alternative being the /ws/jsonrpc
+// sibling route, for clients that can't set a subprotocol header).
+const jsonRPCSubprotocol = "jsonrpc2.0"
+
+const jsonRPCVersion = "2.0"
+
+// RPC method names, mirroring the legacy SignalMessage types they carry the
+// same SDP/ICE payloads as.
+const (
+	RPCMethodJoin    = "join"
+	RPCMethodOffer   = "offer"
+	RPCMethodAnswer  = "answer"
+	RPCMethodTrickle = "trickle"
+)
+
+const rpcErrInternal = -32603
+
+// rpcRequest is an incoming JSON-RPC 2.0 call.
+type rpcRequest struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method"`
+	Params  json.RawMessage `json:"params,omitempty"`
+}
+
+// rpcMessage is an outgoing JSON-RPC 2.0 response (ID set) or
+// server-initiated notification (Method set).
+type rpcMessage struct {
+	JSONRPC string          `json:"jsonrpc"`
+	ID      json.RawMessage `json:"id,omitempty"`
+	Method  string          `json:"method,omitempty"`
+	Params  json.RawMessage `json:"params,omitempty"`
+	Result  json.RawMessage `json:"result,omitempty"`
+	Error   *rpcError       `json:"error,omitempty"`
+}
+
+type rpcError struct {
+	Code    int    `json:"code"`
+	Message string `json:"message"`
+}
+
+type rpcJoinParams struct {
+	SessionID string `json:"sessionId"`
+	Role      string `json:"role"`
+	Token     string `json:"token"`
+}
+
+type rpcSDPParams struct {
+	SessionID    string                    `json:"sessionId"`
+	TargetPeerID string                    `json:"targetPeerId,omitempty"`
+	SDP          webrtc.SessionDescription `json:"sdp"`
+}
+
+type rpcTrickleParams struct {
+	SessionID    string                  `json:"sessionId"`
+	TargetPeerID string                  `json:"targetPeerId,omitempty"`
+	Candidate    webrtc.ICECandidateInit `json:"candidate"`
+}
+
+// sdpNotifyParams / trickleNotifyParams are the notification shapes used to
+// relay an offer/answer/ICE candidate onward to the other peer.
+type sdpNotifyParams struct {
+	SessionID    string          `json:"sessionId"`
+	TargetPeerID string          `json:"targetPeerId,omitempty"`
+	SDP          json.RawMessage `json:"sdp"`
+}
+
+type trickleNotifyParams struct {
+	SessionID    string          `json:"sessionId"`
+	TargetPeerID string          `json:"targetPeerId,omitempty"`
+	Candidate    json.RawMessage `json:"candidate"`
+}
+
+// decodeRPCMessage turns an incoming JSON-RPC request into the internal
+// SignalMessage shape used by handleSignalMessage. Once the envelope itself
+// parses, the returned message's RPCID is populated (even when the method or
+// params are invalid) so the caller can send back a correlated error
+// response; if the envelope doesn't parse, the returned message is empty.
+func decodeRPCMessage(raw json.RawMessage) (SignalMessage, error) {
+	var req rpcRequest
+	if err := json.Unmarshal(raw, &req); err != nil {
+		return SignalMessage{}, err
+	}
+
+	msg := SignalMessage{RPCID: req.ID}
+
+	switch req.Method {
+	case RPCMethodJoin:
+		var p rpcJoinParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return msg, fmt.Errorf("invalid join params: %w", err)
+		}
+		msg.Type = MsgTypeJoin
+		msg.SessionID = p.SessionID
+		msg.Payload, _ = json.Marshal(p)
+	case RPCMethodOffer:
+		var p rpcSDPParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return msg, fmt.Errorf("invalid offer params: %w", err)
+		}
+		msg.Type = MsgTypeOffer
+		msg.SessionID = p.SessionID
+		msg.TargetPeerID = p.TargetPeerID
+		msg.Payload, _ = json.Marshal(p.SDP)
+	case RPCMethodAnswer:
+		var p rpcSDPParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return msg, fmt.Errorf("invalid answer params: %w", err)
+		}
+		msg.Type = MsgTypeAnswer
+		msg.SessionID = p.SessionID
+		msg.TargetPeerID = p.TargetPeerID
+		msg.Payload, _ = json.Marshal(p.SDP)
+	case RPCMethodTrickle:
+		var p rpcTrickleParams
+		if err := json.Unmarshal(req.Params, &p); err != nil {
+			return msg, fmt.Errorf("invalid trickle params: %w", err)
+		}
+		msg.Type = MsgTypeICE
+		msg.SessionID = p.SessionID
+		msg.TargetPeerID = p.TargetPeerID
+		msg.Payload, _ = json.Marshal(p.Candidate)
+	default:
+		return msg, fmt.Errorf("unknown method %q", req.Method)
+	}
+
+	return msg, nil
+}
+
+// writeRPCMessage translates an internal SignalMessage into the JSON-RPC 2.0
+// envelope and writes it to conn. A message with RPCID set is a response to
+// that peer's own call; otherwise it's a server-initiated notification.
+func writeRPCMessage(conn *websocket.Conn, msg SignalMessage) error {
+	if msg.RPCID != nil {
+		resp := rpcMessage{JSONRPC: jsonRPCVersion, ID: msg.RPCID}
+		if msg.Type == MsgTypeError {
+			var errPayload struct {
+				Message string `json:"message"`
+			}
+			json.Unmarshal(msg.Payload, &errPayload)
+			resp.Error = &rpcError{Code: rpcErrInternal, Message: errPayload.Message}
+		} else {
+			resp.Result = msg.Payload
+			if resp.Result == nil {
+				resp.Result = json.RawMessage(`{}`)
+			}
+		}
+		return conn.WriteJSON(resp)
+	}
+
+	notice := rpcMessage{
+		JSONRPC: jsonRPCVersion,
+		Method:  notifyMethodFor(msg.Type),
+		Params:  notifyParams(msg),
+	}
+	return conn.WriteJSON(notice)
+}
+
+// notifyMethodFor maps an internal message type to its JSON-RPC notification
+// method name. Only "ice" is renamed (to "trickle"); the rest already match.
+func notifyMethodFor(msgType string) string {
+	if msgType == MsgTypeICE {
+		return RPCMethodTrickle
+	}
+	return msgType
+}
+
+// notifyParams builds the params object for a server-initiated notification.
+func notifyParams(msg SignalMessage) json.RawMessage {
+	switch msg.Type {
+	case MsgTypeOffer, MsgTypeAnswer:
+		b, _ := json.Marshal(sdpNotifyParams{SessionID: msg.SessionID, TargetPeerID: msg.TargetPeerID, SDP: msg.Payload})
+		return b
+	case MsgTypeICE:
+		b, _ := json.Marshal(trickleNotifyParams{SessionID: msg.SessionID, TargetPeerID: msg.TargetPeerID, Candidate: msg.Payload})
+		return b
+	case MsgTypePeerJoined, MsgTypeReceiverJoined, MsgTypeReady:
+		b, _ := json.Marshal(map[string]string{"sessionId": msg.SessionID, "peerId": msg.PeerID})
+		return b
+	case MsgTypeRequestOffer:
+		b, _ := json.Marshal(map[string]string{"sessionId": msg.SessionID, "targetPeerId": msg.TargetPeerID})
+		return b
+	default:
+		return msg.Payload
+	}
+}
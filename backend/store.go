@@ -0,0 +1,327 @@
+package main
+
+import (
+	"sync"
+	"time"
+)
+
+// SessionMeta is a session's cluster-wide topology as seen by a
+// SessionStore: which peer (if any) holds the sender slot, and which
+// receiver slots are claimed. It carries no live connection state, since
+// WebSocket/PeerConnection objects are inherently tied to whichever pod
+// actually accepted that connection.
+type SessionMeta struct {
+	SenderID    string
+	ReceiverIDs []string
+}
+
+// Event types delivered by SessionStore.WatchPeerJoined.
+const (
+	EventPeerJoined = "peer-joined"
+	EventPeerLeft   = "peer-left"
+	EventExpired    = "expired"
+)
+
+// Event is a cluster-wide session topology change: a peer claimed or
+// released a slot on some pod, or the session itself expired.
+type Event struct {
+	Type   string
+	Role   string
+	PeerID string
+}
+
+// SessionStore owns the durable, cluster-wide session topology so multiple
+// signaling pods behind a load balancer agree on who's in a session and can
+// forward messages to whichever pod is actually hosting the target peer's
+// WebSocket connection. MemorySessionStore below is the single-process
+// default; RedisSessionStore (see redis_store.go) is the horizontally
+// scalable one.
+type SessionStore interface {
+	// Create allocates a new session ID.
+	Create() (string, error)
+	// Get returns a session's claimed topology, or false if it doesn't exist.
+	Get(sessionID string) (SessionMeta, bool, error)
+	// Delete removes a session and all of its claims.
+	Delete(sessionID string) error
+	// Claim reserves role ("sender" or "receiver") for peerID in sessionID,
+	// enforcing single-sender and MaxReceiversPerSession cluster-wide.
+	Claim(sessionID, role, peerID string) error
+	// Unclaim releases peerID's previously claimed slot, e.g. once its peer
+	// disconnects from whichever pod was hosting it.
+	Unclaim(sessionID, peerID string) error
+	// WatchPeerJoined streams topology/expiry events for sessionID so every
+	// pod with a local stake in it can react regardless of which pod the
+	// joining/leaving peer actually connected to. The returned func stops
+	// the watch and releases its resources.
+	WatchPeerJoined(sessionID string) (<-chan Event, func())
+	// Publish forwards msg to whichever pod has deliverTo connected locally,
+	// used by SessionManager for both sender<->receiver relaying and
+	// same-pod delivery (every pod, including the publisher, also receives
+	// its own Publish calls via Subscribe).
+	Publish(sessionID, deliverTo string, msg SignalMessage) error
+	// Subscribe receives every message published for sessionID. Callers
+	// filter for the deliverTo they're hosting locally; the returned func
+	// stops the subscription.
+	Subscribe(sessionID string) (<-chan DeliveryEnvelope, func())
+	// ConsumeToken marks a join token (identified by its signature) spent
+	// for sessionID, returning true if it had already been consumed before
+	// this call — so a replayed join token is rejected cluster-wide even if
+	// the two attempts land on different pods.
+	ConsumeToken(sessionID, tokenSig string) (alreadyUsed bool, err error)
+}
+
+// DeliveryEnvelope pairs a relayed SignalMessage with the peer ID it's
+// meant for, since that isn't always the same as msg.TargetPeerID (the wire
+// field used for pairing disambiguation, e.g. "which receiver is this offer
+// for" on a sender that's juggling several).
+type DeliveryEnvelope struct {
+	DeliverTo string
+	Message   SignalMessage
+}
+
+// MemorySessionStore is the default, single-process SessionStore: claims
+// and pub/sub are just an in-memory map and fanned-out channels. Correct on
+// its own, but two instances of it in different processes can't see each
+// other — that's what RedisSessionStore is for.
+type MemorySessionStore struct {
+	mu       sync.Mutex
+	sessions map[string]*memSession
+}
+
+type memSession struct {
+	meta           SessionMeta
+	createdAt      time.Time
+	consumedTokens map[string]struct{}
+
+	// fanMu guards watchers/subs separately from MemorySessionStore.mu:
+	// notify/broadcast below are called after that lock is released (so a
+	// blocked/slow watcher can't hold up Claim/Unclaim/Publish), but they
+	// still iterate these same maps that WatchPeerJoined/Subscribe and their
+	// stop funcs mutate concurrently.
+	fanMu    sync.Mutex
+	watchers map[chan Event]struct{}
+	subs     map[chan DeliveryEnvelope]struct{}
+}
+
+// NewMemorySessionStore creates an in-process SessionStore and starts its
+// session-expiry sweep (the same 30-minute TTL the vault always used).
+func NewMemorySessionStore() *MemorySessionStore {
+	store := &MemorySessionStore{sessions: make(map[string]*memSession)}
+	go store.cleanupExpired()
+	return store
+}
+
+func (s *MemorySessionStore) Create() (string, error) {
+	sessionID := generateSessionID()
+	s.mu.Lock()
+	s.sessions[sessionID] = &memSession{
+		createdAt:      time.Now(),
+		watchers:       make(map[chan Event]struct{}),
+		subs:           make(map[chan DeliveryEnvelope]struct{}),
+		consumedTokens: make(map[string]struct{}),
+	}
+	s.mu.Unlock()
+	return sessionID, nil
+}
+
+func (s *MemorySessionStore) Get(sessionID string) (SessionMeta, bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return SessionMeta{}, false, nil
+	}
+	return sess.meta.clone(), true, nil
+}
+
+func (s *MemorySessionStore) Delete(sessionID string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	delete(s.sessions, sessionID)
+	return nil
+}
+
+func (s *MemorySessionStore) Claim(sessionID, role, peerID string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		s.mu.Unlock()
+		return &ErrorResponse{Message: "Session not found"}
+	}
+
+	if role == "sender" {
+		if sess.meta.SenderID != "" {
+			s.mu.Unlock()
+			return &ErrorResponse{Message: "Sender already connected"}
+		}
+		sess.meta.SenderID = peerID
+	} else {
+		if len(sess.meta.ReceiverIDs) >= MaxReceiversPerSession {
+			s.mu.Unlock()
+			return &ErrorResponse{Message: "Session already has the maximum number of receivers"}
+		}
+		sess.meta.ReceiverIDs = append(sess.meta.ReceiverIDs, peerID)
+	}
+	s.mu.Unlock()
+
+	sess.notify(Event{Type: EventPeerJoined, Role: role, PeerID: peerID})
+	return nil
+}
+
+func (s *MemorySessionStore) Unclaim(sessionID, peerID string) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		s.mu.Unlock()
+		return nil
+	}
+
+	role := ""
+	if sess.meta.SenderID == peerID {
+		sess.meta.SenderID = ""
+		role = "sender"
+	}
+	for i, id := range sess.meta.ReceiverIDs {
+		if id == peerID {
+			sess.meta.ReceiverIDs = append(sess.meta.ReceiverIDs[:i], sess.meta.ReceiverIDs[i+1:]...)
+			role = "receiver"
+			break
+		}
+	}
+	s.mu.Unlock()
+
+	if role != "" {
+		sess.notify(Event{Type: EventPeerLeft, Role: role, PeerID: peerID})
+	}
+	return nil
+}
+
+func (s *MemorySessionStore) WatchPeerJoined(sessionID string) (<-chan Event, func()) {
+	ch := make(chan Event, 8)
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if ok {
+		sess.fanMu.Lock()
+		sess.watchers[ch] = struct{}{}
+		sess.fanMu.Unlock()
+	}
+
+	// Closing ch on stop lets a consumer ranging/selecting on it see the
+	// watch end; it's removed from watchers under fanMu first, so notify
+	// can't be mid-send on it when it's closed.
+	var once sync.Once
+	stop := func() {
+		once.Do(func() {
+			if ok {
+				sess.fanMu.Lock()
+				delete(sess.watchers, ch)
+				sess.fanMu.Unlock()
+			}
+			close(ch)
+		})
+	}
+	return ch, stop
+}
+
+func (s *MemorySessionStore) Publish(sessionID, deliverTo string, msg SignalMessage) error {
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if !ok {
+		return &ErrorResponse{Message: "Session not found"}
+	}
+	sess.broadcast(DeliveryEnvelope{DeliverTo: deliverTo, Message: msg})
+	return nil
+}
+
+func (s *MemorySessionStore) ConsumeToken(sessionID, tokenSig string) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	sess, ok := s.sessions[sessionID]
+	if !ok {
+		return false, &ErrorResponse{Message: "Session not found"}
+	}
+	if _, used := sess.consumedTokens[tokenSig]; used {
+		return true, nil
+	}
+	sess.consumedTokens[tokenSig] = struct{}{}
+	return false, nil
+}
+
+func (s *MemorySessionStore) Subscribe(sessionID string) (<-chan DeliveryEnvelope, func()) {
+	ch := make(chan DeliveryEnvelope, 32)
+	s.mu.Lock()
+	sess, ok := s.sessions[sessionID]
+	s.mu.Unlock()
+	if ok {
+		sess.fanMu.Lock()
+		sess.subs[ch] = struct{}{}
+		sess.fanMu.Unlock()
+	}
+
+	stop := func() {
+		s.mu.Lock()
+		sess, ok := s.sessions[sessionID]
+		s.mu.Unlock()
+		if ok {
+			sess.fanMu.Lock()
+			delete(sess.subs, ch)
+			sess.fanMu.Unlock()
+		}
+	}
+	return ch, stop
+}
+
+// cleanupExpired mirrors the vault's original 30-minute session TTL.
+// RedisSessionStore gets this for free from Redis key TTLs and keyspace
+// notifications instead (see redis_store.go).
+func (s *MemorySessionStore) cleanupExpired() {
+	ticker := time.NewTicker(5 * time.Minute)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		s.mu.Lock()
+		expired := make([]*memSession, 0)
+		for sessionID, sess := range s.sessions {
+			if time.Since(sess.createdAt) > 30*time.Minute {
+				expired = append(expired, sess)
+				delete(s.sessions, sessionID)
+			}
+		}
+		s.mu.Unlock()
+
+		for _, sess := range expired {
+			sess.notify(Event{Type: EventExpired})
+		}
+	}
+}
+
+func (m SessionMeta) clone() SessionMeta {
+	receivers := make([]string, len(m.ReceiverIDs))
+	copy(receivers, m.ReceiverIDs)
+	return SessionMeta{SenderID: m.SenderID, ReceiverIDs: receivers}
+}
+
+func (sess *memSession) notify(evt Event) {
+	sess.fanMu.Lock()
+	defer sess.fanMu.Unlock()
+	for ch := range sess.watchers {
+		select {
+		case ch <- evt:
+		default:
+		}
+	}
+}
+
+func (sess *memSession) broadcast(env DeliveryEnvelope) {
+	sess.fanMu.Lock()
+	defer sess.fanMu.Unlock()
+	for ch := range sess.subs {
+		select {
+		case ch <- env:
+		default:
+		}
+	}
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"sync"
+	"testing"
+)
+
+// TestMemorySessionStoreConcurrentClaimAndWatch hammers Claim/Unclaim against
+// concurrent WatchPeerJoined/Subscribe registration and cancellation on the
+// same session. It doesn't assert much on its own, but under `go test -race`
+// it catches notify/broadcast iterating sess.watchers/sess.subs while a
+// watcher is concurrently registering or unregistering.
+func TestMemorySessionStoreConcurrentClaimAndWatch(t *testing.T) {
+	store := NewMemorySessionStore()
+	sessionID, err := store.Create()
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	const iterations = 200
+	var wg sync.WaitGroup
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			peerID := "receiver"
+			if err := store.Claim(sessionID, "receiver", peerID); err == nil {
+				store.Unclaim(sessionID, peerID)
+			}
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			_, stop := store.WatchPeerJoined(sessionID)
+			stop()
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			_, stop := store.Subscribe(sessionID)
+			stop()
+		}
+	}()
+
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		for i := 0; i < iterations; i++ {
+			store.Publish(sessionID, "someone", SignalMessage{Type: MsgTypeReceiverJoined})
+		}
+	}()
+
+	wg.Wait()
+}
@@ -0,0 +1,112 @@
+package main
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"log"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// joinTokenTTL is how long a freshly issued sender/receiver token stays
+// valid before a client needs a freshly (re)issued one.
+const joinTokenTTL = 1 * time.Hour
+
+// joinSecret signs and verifies session join tokens; set once at startup by
+// resolveJoinSecret from -secret or the VAULT_JOIN_SECRET env var.
+var joinSecret string
+
+// resolveJoinSecret picks the HMAC secret used to sign join tokens: the
+// -secret flag, then the VAULT_JOIN_SECRET env var, then (dev-only) a
+// random secret that invalidates every issued token across restarts.
+func resolveJoinSecret(flagValue string) string {
+	if flagValue != "" {
+		return flagValue
+	}
+	if envValue := os.Getenv("VAULT_JOIN_SECRET"); envValue != "" {
+		return envValue
+	}
+
+	secret := make([]byte, 32)
+	rand.Read(secret)
+	log.Printf("⚠️  No -secret or VAULT_JOIN_SECRET set; generated a random join-token secret for this run only")
+	return hex.EncodeToString(secret)
+}
+
+// signJoinToken issues a capability token for sessionID/role, good until
+// expiry: base64url(sessionId|role|expiry|HMAC-SHA256(joinSecret, sessionId|role|expiry)).
+// Knowing a session ID no longer lets a third party join it — only whoever
+// holds the matching token can, and receiverToken (not the bare session ID)
+// is what the sender is meant to actually share out-of-band.
+func signJoinToken(sessionID, role string, expiry time.Time) string {
+	payload := fmt.Sprintf("%s|%s|%d", sessionID, role, expiry.Unix())
+	return base64.RawURLEncoding.EncodeToString([]byte(payload + "|" + signPayload(payload)))
+}
+
+func signPayload(payload string) string {
+	mac := hmac.New(sha256.New, []byte(joinSecret))
+	mac.Write([]byte(payload))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// joinToken is a signature-verified, not-yet-fully-checked join token;
+// checkJoinToken still has to check it against the specific join attempt.
+type joinToken struct {
+	SessionID string
+	Role      string
+	Expiry    time.Time
+	Signature string
+}
+
+// parseJoinToken decodes token and checks its HMAC signature, but not
+// expiry, session/role match, or single-use.
+func parseJoinToken(token string) (*joinToken, error) {
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		return nil, &ErrorResponse{Message: "Malformed token"}
+	}
+
+	parts := strings.SplitN(string(raw), "|", 4)
+	if len(parts) != 4 {
+		return nil, &ErrorResponse{Message: "Malformed token"}
+	}
+	sessionID, role, expiryStr, sig := parts[0], parts[1], parts[2], parts[3]
+
+	expiryUnix, err := strconv.ParseInt(expiryStr, 10, 64)
+	if err != nil {
+		return nil, &ErrorResponse{Message: "Malformed token"}
+	}
+
+	payload := sessionID + "|" + role + "|" + expiryStr
+	if !hmac.Equal([]byte(sig), []byte(signPayload(payload))) {
+		return nil, &ErrorResponse{Message: "Invalid token signature"}
+	}
+
+	return &joinToken{SessionID: sessionID, Role: role, Expiry: time.Unix(expiryUnix, 0), Signature: sig}, nil
+}
+
+// checkJoinToken validates token's signature, expiry, and session/role
+// match, but does not enforce single-use. Callers that just need proof the
+// caller holds a valid token for some session/role — without spending it
+// the way an actual join does — use this directly (e.g.
+// handleRotateReceiverToken checking for a live senderToken); handleJoin
+// wraps it with ConsumeToken once the corresponding slot claim succeeds.
+func checkJoinToken(token, sessionID, role string) (*joinToken, error) {
+	parsed, err := parseJoinToken(token)
+	if err != nil {
+		return nil, err
+	}
+	if parsed.SessionID != sessionID || parsed.Role != role {
+		return nil, &ErrorResponse{Message: "Token does not match session or role"}
+	}
+	if time.Now().After(parsed.Expiry) {
+		return nil, &ErrorResponse{Message: "Token expired"}
+	}
+	return parsed, nil
+}
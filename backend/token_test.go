@@ -0,0 +1,95 @@
+package main
+
+import (
+	"encoding/base64"
+	"testing"
+	"time"
+)
+
+func withJoinSecret(t *testing.T, secret string) {
+	t.Helper()
+	prev := joinSecret
+	joinSecret = secret
+	t.Cleanup(func() { joinSecret = prev })
+}
+
+func TestCheckJoinTokenValid(t *testing.T) {
+	withJoinSecret(t, "test-secret")
+
+	token := signJoinToken("session-1", "sender", time.Now().Add(time.Hour))
+	parsed, err := checkJoinToken(token, "session-1", "sender")
+	if err != nil {
+		t.Fatalf("checkJoinToken: unexpected error: %v", err)
+	}
+	if parsed.SessionID != "session-1" || parsed.Role != "sender" {
+		t.Fatalf("checkJoinToken: got session=%q role=%q", parsed.SessionID, parsed.Role)
+	}
+}
+
+func TestCheckJoinTokenRejectsTamperedSignature(t *testing.T) {
+	withJoinSecret(t, "test-secret")
+
+	token := signJoinToken("session-1", "receiver", time.Now().Add(time.Hour))
+	// Tamper with the decoded signature itself: the token's last base64
+	// character also carries padding bits the decoder ignores, so editing
+	// the encoded string alone doesn't reliably change the signature.
+	raw, err := base64.RawURLEncoding.DecodeString(token)
+	if err != nil {
+		t.Fatalf("test setup: decoding token: %v", err)
+	}
+	raw[len(raw)-1] ^= 1
+	tampered := base64.RawURLEncoding.EncodeToString(raw)
+	if tampered == token {
+		t.Fatal("test setup: tampering did not change the token")
+	}
+
+	if _, err := checkJoinToken(tampered, "session-1", "receiver"); err == nil {
+		t.Fatal("checkJoinToken: expected error for tampered token signature, got nil")
+	}
+}
+
+func TestCheckJoinTokenRejectsMismatchedSessionOrRole(t *testing.T) {
+	withJoinSecret(t, "test-secret")
+
+	token := signJoinToken("session-1", "sender", time.Now().Add(time.Hour))
+
+	if _, err := checkJoinToken(token, "session-2", "sender"); err == nil {
+		t.Fatal("checkJoinToken: expected error for mismatched session ID, got nil")
+	}
+	if _, err := checkJoinToken(token, "session-1", "receiver"); err == nil {
+		t.Fatal("checkJoinToken: expected error for mismatched role, got nil")
+	}
+}
+
+func TestCheckJoinTokenRejectsExpiredToken(t *testing.T) {
+	withJoinSecret(t, "test-secret")
+
+	token := signJoinToken("session-1", "sender", time.Now().Add(-time.Minute))
+	if _, err := checkJoinToken(token, "session-1", "sender"); err == nil {
+		t.Fatal("checkJoinToken: expected error for expired token, got nil")
+	}
+}
+
+func TestConsumeTokenRejectsReplay(t *testing.T) {
+	store := NewMemorySessionStore()
+	sessionID, err := store.Create()
+	if err != nil {
+		t.Fatalf("Create: unexpected error: %v", err)
+	}
+
+	alreadyUsed, err := store.ConsumeToken(sessionID, "some-signature")
+	if err != nil {
+		t.Fatalf("ConsumeToken (first use): unexpected error: %v", err)
+	}
+	if alreadyUsed {
+		t.Fatal("ConsumeToken (first use): expected alreadyUsed=false")
+	}
+
+	alreadyUsed, err = store.ConsumeToken(sessionID, "some-signature")
+	if err != nil {
+		t.Fatalf("ConsumeToken (replay): unexpected error: %v", err)
+	}
+	if !alreadyUsed {
+		t.Fatal("ConsumeToken (replay): expected alreadyUsed=true for a replayed signature")
+	}
+}